@@ -0,0 +1,168 @@
+package internal
+
+import (
+	_ "embed"
+	"encoding/json"
+	"slices"
+	"time"
+
+	ics "github.com/arran4/golang-ical"
+)
+
+//go:embed semesters.json
+var semestersJson string
+
+// date is a day like 2024-01-09 in our local timezone
+type date struct {
+	time.Time
+}
+
+func (d *date) UnmarshalJSON(raw []byte) error {
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return err
+	}
+	t, err := time.ParseInLocation(time.DateOnly, value, tumLocation)
+	d.Time = t
+	return err
+}
+
+// period is a named range of days, including its first and last day
+type period struct {
+	Name  string `json:"name"`
+	Start date   `json:"start"`
+	End   date   `json:"end"`
+}
+
+// contains reports whether t is on one of the days of the period
+func (p period) contains(t time.Time) bool {
+	return !t.Before(p.Start.Time) && t.Before(p.End.AddDate(0, 0, 1))
+}
+
+// overlaps reports whether one of the days of the period is in [from, to)
+func (p period) overlaps(from time.Time, to time.Time) bool {
+	return p.Start.Before(to) && p.End.AddDate(0, 0, 1).After(from)
+}
+
+type semester struct {
+	period
+	LecturesStart date     `json:"lecturesStart"`
+	LecturesEnd   date     `json:"lecturesEnd"`
+	LectureFree   []period `json:"lectureFree"`
+}
+
+type holiday struct {
+	Name string `json:"name"`
+	Date date   `json:"date"`
+}
+
+// academicCalendar holds the TUM semester dates and the Bavarian public holidays from semesters.json.
+// The file is versioned, so it has to be extended with every new academic year.
+type academicCalendar struct {
+	Version   string     `json:"version"`
+	Semesters []semester `json:"semesters"`
+	Holidays  []holiday  `json:"holidays"`
+}
+
+// academic is the academic calendar shipped with the proxy
+var academic *academicCalendar
+
+// the dates are parsed in tumLocation, which the initialization order of package variables doesn't know about
+func init() {
+	academic = mustParseAcademicCalendar(semestersJson)
+}
+
+func mustParseAcademicCalendar(raw string) *academicCalendar {
+	var c academicCalendar
+	if err := json.Unmarshal([]byte(raw), &c); err != nil {
+		panic(err)
+	}
+	return &c
+}
+
+// semesterOn returns the semester t is in, if semesters.json lists it
+func (c *academicCalendar) semesterOn(t time.Time) (semester, bool) {
+	for _, s := range c.Semesters {
+		if s.contains(t.In(tumLocation)) {
+			return s, true
+		}
+	}
+	return semester{}, false
+}
+
+// holidayOn returns the public holiday on the day of t, if there is one
+func (c *academicCalendar) holidayOn(t time.Time) (holiday, bool) {
+	day := startOfDay(t.In(tumLocation))
+	for _, h := range c.Holidays {
+		if h.Date.Equal(day) {
+			return h, true
+		}
+	}
+	return holiday{}, false
+}
+
+// addAllDayEvent adds a transparent all-day event for the days of the period, so it is shown without blocking time
+func addAllDayEvent(cal *ics.Calendar, uid string, p period, category string, now time.Time) {
+	event := cal.AddEvent(uid)
+	event.SetDtStampTime(now)
+	event.SetAllDayStartAt(p.Start.Time)
+	event.SetAllDayEndAt(p.End.AddDate(0, 0, 1)) // the end of all-day events is exclusive
+	event.SetSummary(p.Name)
+	event.AddCategory(category)
+	event.SetTimeTransparency(ics.TransparencyTransparent)
+}
+
+// addHolidays adds the public holidays in [from, to) as all-day events
+func (c *academicCalendar) addHolidays(cal *ics.Calendar, from time.Time, to time.Time, now time.Time) {
+	for _, h := range c.Holidays {
+		if !(period{Start: h.Date, End: h.Date}).overlaps(from, to) {
+			continue
+		}
+		day := h.Date.Format(time.DateOnly)
+		addAllDayEvent(cal, "holiday-"+day+"@cal.tum.app", period{Name: h.Name, Start: h.Date, End: h.Date}, "Holiday", now)
+	}
+}
+
+// addPeriods adds the semesters, their lecture periods and the lecture-free periods during them as all-day events,
+// as far as they overlap [from, to)
+func (c *academicCalendar) addPeriods(cal *ics.Calendar, lang *language, from time.Time, to time.Time, now time.Time) {
+	for _, s := range c.Semesters {
+		if !s.overlaps(from, to) {
+			continue
+		}
+		id := s.Start.Format(time.DateOnly)
+		addAllDayEvent(cal, "semester-"+id+"@cal.tum.app", s.period, "Semester", now)
+		lectures := period{Name: lang.label("lecturePeriod", s.Name), Start: s.LecturesStart, End: s.LecturesEnd}
+		if lectures.overlaps(from, to) {
+			addAllDayEvent(cal, "lectures-"+id+"@cal.tum.app", lectures, "Semester", now)
+		}
+		for _, p := range s.LectureFree {
+			if p.overlaps(from, to) {
+				addAllDayEvent(cal, "lecture-free-"+p.Start.Format(time.DateOnly)+"@cal.tum.app", p, "Lecture-free", now)
+			}
+		}
+	}
+}
+
+// academicRange returns the days to add holidays and periods for: the window of the feed, or where it is open,
+// the days of its events. Without events and window, there is nothing to add.
+func academicRange(events []*Event, from time.Time, to time.Time) (time.Time, time.Time, bool) {
+	if len(events) == 0 && (from.IsZero() || to.IsZero()) {
+		return from, to, false
+	}
+	if from.IsZero() {
+		from = startOfDay(slices.MinFunc(events, func(a, b *Event) int { return a.Start.Compare(b.Start) }).Start.In(tumLocation))
+	}
+	if to.IsZero() {
+		to = slices.MaxFunc(events, func(a, b *Event) int { return a.End.Compare(b.End) }).End
+	}
+	return from, to, true
+}
+
+// removeHolidayEvents drops the events TUMonline still lists on public holidays
+func (c *academicCalendar) removeHolidayEvents(cal *ics.Calendar, events []*Event) []*Event {
+	return removeEvents(cal, events, func(e *Event) bool {
+		_, ok := c.holidayOn(e.Start)
+		return ok
+	})
+}
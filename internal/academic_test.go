@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	ics "github.com/arran4/golang-ical"
+)
+
+func TestAcademicCalendar(t *testing.T) {
+	if len(academic.Semesters) == 0 || academic.Version == "" {
+		t.Fatal("semesters.json should contain versioned semesters")
+	}
+	for _, s := range academic.Semesters {
+		if !s.Start.Before(s.LecturesStart.Time) || !s.LecturesEnd.Before(s.End.Time) {
+			t.Errorf("The lectures of %s should be within the semester", s.Name)
+		}
+		for _, p := range s.LectureFree {
+			if !s.contains(p.Start.Time) || !s.contains(p.End.Time) {
+				t.Errorf("%s should be within %s", p.Name, s.Name)
+			}
+		}
+	}
+
+	if h, ok := academic.holidayOn(time.Date(2024, time.May, 30, 10, 0, 0, 0, tumLocation)); !ok || h.Name != "Fronleichnam" {
+		t.Errorf("30.05.2024 should be Fronleichnam but got %v", h)
+	}
+	if _, ok := academic.holidayOn(time.Date(2024, time.May, 29, 23, 30, 0, 0, time.UTC)); !ok {
+		t.Error("Holidays should be checked in our timezone")
+	}
+	if _, ok := academic.holidayOn(time.Date(2024, time.May, 29, 10, 0, 0, 0, tumLocation)); ok {
+		t.Error("29.05.2024 is no holiday")
+	}
+}
+
+func TestAddHolidays(t *testing.T) {
+	cal := ics.NewCalendar()
+	from := time.Date(2023, time.December, 1, 0, 0, 0, 0, tumLocation)
+	to := time.Date(2024, time.February, 1, 0, 0, 0, 0, tumLocation)
+	academic.addHolidays(cal, from, to, time.Now())
+	academic.addPeriods(cal, defaultLanguage, from, to, time.Now())
+	serialized := cal.Serialize()
+	for _, expected := range []string{"UID:holiday-2024-01-01@cal.tum.app", "DTSTART;VALUE=DATE:20240101", "DTEND;VALUE=DATE:20240102", "SUMMARY:Weihnachtsferien", "TRANSP:TRANSPARENT"} {
+		if !strings.Contains(serialized, expected) {
+			t.Errorf("Calendar should contain %s", expected)
+		}
+	}
+	for _, unexpected := range []string{"UID:holiday-2024-05-30@cal.tum.app", "UID:semester-2024-04-01@cal.tum.app", "UID:lecture-free-2024-05-21@cal.tum.app"} {
+		if strings.Contains(serialized, unexpected) {
+			t.Errorf("Calendar should only contain the days between %s and %s, but contains %s", from, to, unexpected)
+		}
+	}
+}
+
+func TestAcademicRange(t *testing.T) {
+	day := time.Date(2024, time.January, 9, 0, 0, 0, 0, tumLocation)
+	events := []*Event{testEvent("GAD", day.Add(34*time.Hour), 90), testEvent("ERA", day.Add(10*time.Hour), 90)}
+	from, to, ok := academicRange(events, time.Time{}, time.Time{})
+	if !ok || !from.Equal(day) || !to.Equal(events[0].End) {
+		t.Errorf("Without a window, the range should span the events but is %s to %s", from, to)
+	}
+	window := day.AddDate(0, 1, 0)
+	if from, to, _ := academicRange(events, time.Time{}, window); !from.Equal(day) || !to.Equal(window) {
+		t.Errorf("The window should be kept where it is set but got %s to %s", from, to)
+	}
+	if _, _, ok := academicRange(nil, time.Time{}, window); ok {
+		t.Error("Without events, a half-open window can't be closed")
+	}
+}
+
+func TestRemoveHolidayEvents(t *testing.T) {
+	cal := ics.NewCalendar()
+	var events []*Event
+	for i, day := range []int{29, 30, 31} {
+		e := testEvent("ERA", time.Date(2024, time.May, day, 10, 0, 0, 0, tumLocation), 90)
+		e.UID = string(rune('a' + i))
+		cal.AddEvent(e.UID)
+		events = append(events, e)
+	}
+
+	events = academic.removeHolidayEvents(cal, events)
+	if len(events) != 2 || len(cal.Events()) != 2 {
+		t.Fatalf("Only the event on Fronleichnam should be removed, got %d events and %d components", len(events), len(cal.Events()))
+	}
+	if cal.Events()[1].Id() != "c" || events[1].UID != "c" {
+		t.Error("The remaining events should keep their order")
+	}
+}
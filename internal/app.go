@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"bytes"
 	"embed"
 	"encoding/json"
 	"errors"
@@ -9,9 +10,12 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"os"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
+	"time"
 
 	ics "github.com/arran4/golang-ical"
 	"github.com/getsentry/sentry-go"
@@ -25,6 +29,9 @@ var coursesJson string
 //go:embed buildings.json
 var buildingsJson string
 
+//go:embed schools.json
+var schoolsJson string
+
 //go:embed static
 var static embed.FS
 
@@ -35,7 +42,23 @@ type App struct {
 	engine *gin.Engine
 
 	courseReplacements   []*Replacement
+	courses              []*CourseMetadata
 	buildingReplacements map[string]string
+	campuses             map[string]string
+	travelTimes          map[[2]string]time.Duration
+	// lang labels the events, see forLanguage
+	lang *language
+	// base is the app without a language, whose course names identify courses in parameters like color= and group=
+	base *App
+
+	// store persists state between fetches, e.g. for change tracking. It is nil if no data directory is available.
+	store *Store
+	// webhookBackoff is the delay before the first retry of a failed webhook delivery, doubled for each further retry
+	webhookBackoff time.Duration
+	// webhookClient delivers the webhooks, refusing to connect to internal addresses
+	webhookClient *http.Client
+	// mailer sends the weekly digests. It is nil if no SMTP server is configured.
+	mailer *Mailer
 }
 
 type Replacement struct {
@@ -44,8 +67,19 @@ type Replacement struct {
 }
 
 type Course struct {
+	// Summary names the course in parameters like color and group, the same in every language
 	Summary string `json:"summary"`
-	Hide    bool   `json:"hide"`
+	// Title is the summary in the language of the request, for display
+	Title    string          `json:"title"`
+	Hide     bool            `json:"hide"`
+	Color    string          `json:"color"`
+	Metadata *CourseMetadata `json:"metadata,omitempty"`
+	// Conflicts are the other courses overlapping with this one at least once
+	Conflicts []string `json:"conflicts,omitempty"`
+	// Groups are the exercise or tutorial groups of the course, if there are several to choose from
+	Groups []string `json:"groups,omitempty"`
+	// ChosenGroups are the groups kept by the group parameter, all groups are kept if it is empty
+	ChosenGroups []string `json:"chosenGroups,omitempty"`
 }
 
 // for sorting replacements by length, then alphabetically
@@ -60,22 +94,27 @@ func (r1 *Replacement) isLessThan(r2 *Replacement) bool {
 }
 
 func newApp() (*App, error) {
-	a := App{}
+	a := App{webhookBackoff: time.Second, webhookClient: newWebhookClient(), lang: defaultLanguage}
 
 	// courseReplacements is a map of course names to shortened names.
 	// We sort it by length, then alphabetically to ensure a consistent execution order
-	var rawCourseReplacements map[string]string
-	if err := json.Unmarshal([]byte(coursesJson), &rawCourseReplacements); err != nil {
+	courseReplacements, courses, err := parseCourses([]byte(coursesJson))
+	if err != nil {
 		return nil, err
 	}
-	for key, value := range rawCourseReplacements {
-		a.courseReplacements = append(a.courseReplacements, &Replacement{key, value})
-	}
+	a.courseReplacements = courseReplacements
 	sort.Slice(a.courseReplacements, func(i, j int) bool { return a.courseReplacements[i].isLessThan(a.courseReplacements[j]) })
+	// courses holds the structured metadata of well-known courses, sorted by name for a stable lookup order
+	a.courses = courses
+	sort.Slice(a.courses, func(i, j int) bool { return a.courses[i].LongName < a.courses[j].LongName })
 	// buildingReplacements is a map of room numbers to building names
 	if err := json.Unmarshal([]byte(buildingsJson), &a.buildingReplacements); err != nil {
 		return nil, err
 	}
+	// campuses maps building numbers to the campus they are on, travelTimes the time to get between campuses
+	if a.campuses, a.travelTimes, err = parseCampuses([]byte(campusesJson), a.buildingReplacements); err != nil {
+		return nil, err
+	}
 	return &a, nil
 }
 
@@ -135,6 +174,18 @@ func (a *App) Run() error {
 		return err
 	}
 
+	// The data directory holds snapshots of the subscriptions for change tracking
+	dataDir := os.Getenv("DATA_DIR")
+	if dataDir == "" {
+		dataDir = "data"
+	}
+	if a.store, err = newStore(dataDir); err != nil {
+		fmt.Printf("Change tracking disabled: %v\n", err)
+	}
+	if a.mailer = newMailerFromEnv(); a.mailer != nil && a.store != nil {
+		go a.scheduleDigests()
+	}
+
 	// Setup Gin with sentry traces, logger and routes
 	gin.SetMode("release")
 	a.engine = gin.New()
@@ -149,12 +200,33 @@ func (a *App) Run() error {
 
 func (a *App) configRoutes() {
 	a.engine.GET("/api/courses", a.handleGetCourses)
+	a.engine.GET("/api/events", a.handleGetEvents)
+	a.engine.GET("/api/exams", a.handleGetExams)
+	a.engine.GET("/freebusy", a.handleFreeBusy)
+	a.engine.GET("/api/common-free", a.handleCommonFree)
+	a.engine.POST("/api/subscriptions", a.handleStoreSubscription)
+	a.engine.DELETE("/api/subscriptions", a.handleDeleteSubscription)
+	a.engine.GET("/api/changes", a.handleGetChanges)
+	a.engine.GET("/changes.atom", a.handleChangesFeed)
+	a.engine.GET("/api/webhooks", a.handleGetWebhooks)
+	a.engine.POST("/api/webhooks", a.handleAddWebhook)
+	a.engine.DELETE("/api/webhooks/:id", a.handleDeleteWebhook)
+	a.engine.POST("/api/digest", a.handleSubscribeDigest)
+	a.engine.GET("/digest/confirm", a.handleConfirmDigest)
+	a.engine.GET("/digest/unsubscribe", a.handleUnsubscribeDigest)
+	for _, method := range []string{http.MethodOptions, http.MethodGet, http.MethodHead, "PROPFIND", "REPORT", http.MethodPut, http.MethodDelete, "PROPPATCH", "MKCALENDAR", "MKCOL", "MOVE", "COPY"} {
+		a.engine.Handle(method, caldavRoot+"*path", a.handleCalDAV)
+	}
+	a.engine.Any("/.well-known/caldav", func(c *gin.Context) {
+		c.Redirect(http.StatusMovedPermanently, caldavRoot)
+	})
 	a.engine.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
 			"status": "ok",
 		})
 	})
 	a.engine.Any("/", a.handleIcal)
+	a.engine.GET("/view", a.handleView)
 	f := http.FS(static)
 	a.engine.StaticFS("/files/", f)
 	a.engine.NoMethod(func(c *gin.Context) {
@@ -163,10 +235,8 @@ func (a *App) configRoutes() {
 }
 
 func getUrl(c *gin.Context) string {
-	stud := c.Query("pStud")
-	pers := c.Query("pPers")
-	token := c.Query("pToken")
-	if (stud == "" && pers == "") || token == "" {
+	calendarURL := getCalendarURL(c)
+	if calendarURL == "" {
 		// Missing parameters: just serve our landing page
 		f, err := static.Open("static/index.html")
 		if err != nil {
@@ -182,10 +252,46 @@ func getUrl(c *gin.Context) string {
 		}
 		return ""
 	}
+	return calendarURL
+}
+
+// getCalendarURL returns the TUMonline URL of the requested calendar, or an empty string if parameters are missing
+func getCalendarURL(c *gin.Context) string {
+	stud := c.Query("pStud")
+	pers := c.Query("pPers")
+	token := c.Query("pToken")
+	if (stud == "" && pers == "") || token == "" {
+		return ""
+	}
+	return tumOnlineURL(stud, pers, token)
+}
+
+// tumOnlineExport is the calendar export of TUMonline, a variable so tests can serve their own calendars
+var tumOnlineExport = "https://campus.tum.de/tumonlinej/ws/termin/ical"
+
+// tumOnlineURL returns the calendar export URL of a student (pStud) or employee (pPers)
+func tumOnlineURL(stud string, pers string, token string) string {
 	if stud == "" {
-		return fmt.Sprintf("https://campus.tum.de/tumonlinej/ws/termin/ical?pPers=%s&pToken=%s", pers, token)
+		return fmt.Sprintf("%s?pPers=%s&pToken=%s", tumOnlineExport, url.QueryEscape(pers), url.QueryEscape(token))
 	}
-	return fmt.Sprintf("https://campus.tum.de/tumonlinej/ws/termin/ical?pStud=%s&pToken=%s", stud, token)
+	return fmt.Sprintf("%s?pStud=%s&pToken=%s", tumOnlineExport, url.QueryEscape(stud), url.QueryEscape(token))
+}
+
+// fetchCalendar downloads the raw calendar from TUMonline
+func fetchCalendar(calendarURL string) ([]byte, error) {
+	resp, err := http.Get(calendarURL)
+	if err != nil {
+		return nil, fmt.Errorf("can't fetch calendar: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("can't fetch calendar: %s", resp.Status)
+	}
+	all, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("can't read calendar: %w", err)
+	}
+	return all, nil
 }
 
 func getCalendar(ctx *gin.Context) ([]byte, map[string]bool, error) {
@@ -193,13 +299,9 @@ func getCalendar(ctx *gin.Context) ([]byte, map[string]bool, error) {
 	if fetchURL == "" {
 		return nil, nil, errors.New("no fetchable URL passed")
 	}
-	resp, err := http.Get(fetchURL)
+	all, err := fetchCalendar(fetchURL)
 	if err != nil {
-		return nil, nil, fmt.Errorf("can't fetch calendar: %w", err)
-	}
-	all, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, nil, fmt.Errorf("can't read calendar: %w", err)
+		return nil, nil, err
 	}
 
 	// Create map of all hidden courses
@@ -214,20 +316,64 @@ func getCalendar(ctx *gin.Context) ([]byte, map[string]bool, error) {
 
 // handleIcal returns a filtered calendar with all courses that are currently offered on campus.
 func (a *App) handleIcal(ctx *gin.Context) {
-	allEvents, hiddenCourses, err := getCalendar(ctx)
-	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusInternalServerError, err)
+	calendarURL := getUrl(ctx)
+	if calendarURL == "" {
 		return
 	}
-
-	cleaned, err := a.getCleanedCalendar(allEvents, hiddenCourses)
-	if err != nil {
-		ctx.AbortWithStatus(http.StatusInternalServerError)
+	cleaned, events, ok := a.requestFeed(ctx, calendarURL)
+	if !ok {
 		return
 	}
 
-	response := []byte(cleaned.Serialize())
-	ctx.Header("Content-Type", "text/calendar")
+	var response []byte
+	var contentType string
+	var err error
+	switch responseFormat(ctx) {
+	case "ics":
+		response = []byte(cleaned.Serialize())
+		contentType = "text/calendar"
+	case "jcal":
+		if response, err = json.Marshal(toJCal(cleaned)); err != nil {
+			sentry.CaptureException(err)
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		contentType = "application/calendar+json"
+	case "csv":
+		var buf bytes.Buffer
+		if err := writeCSV(&buf, events); err != nil {
+			sentry.CaptureException(err)
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		response = buf.Bytes()
+		contentType = "text/csv; charset=utf-8"
+		ctx.Header("Content-Disposition", `attachment; filename="schedule.csv"`)
+	case "xlsx":
+		var buf bytes.Buffer
+		if err := writeXLSX(&buf, events); err != nil {
+			sentry.CaptureException(err)
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		response = buf.Bytes()
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		ctx.Header("Content-Disposition", `attachment; filename="schedule.xlsx"`)
+	case "pdf":
+		var buf bytes.Buffer
+		if err := writeTimetablePDF(&buf, events, time.Now()); err != nil {
+			sentry.CaptureException(err)
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		response = buf.Bytes()
+		contentType = "application/pdf"
+		ctx.Header("Content-Disposition", `inline; filename="timetable.pdf"`)
+	default:
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	ctx.Header("Content-Type", contentType)
 	ctx.Header("Content-Length", fmt.Sprintf("%d", len(response)))
 
 	if _, err := ctx.Writer.Write(response); err != nil {
@@ -235,32 +381,83 @@ func (a *App) handleIcal(ctx *gin.Context) {
 	}
 }
 
+// responseFormat returns the requested format of the calendar, either via the format parameter or the Accept header
+func responseFormat(ctx *gin.Context) string {
+	if format := ctx.Query("format"); format != "" {
+		return strings.ToLower(format)
+	}
+	if strings.Contains(ctx.GetHeader("Accept"), "application/calendar+json") {
+		return "jcal"
+	}
+	return "ics"
+}
+
 // handleGetCourses returns a list of all courses that are currently offered on campus.
 // This is used to populate the dropdown in the landing page for hiding courses.
 func (a *App) handleGetCourses(ctx *gin.Context) {
+	a, ok := a.localized(ctx)
+	if !ok {
+		return
+	}
+
 	allEvents, hidden, err := getCalendar(ctx)
 	if err != nil {
 		ctx.AbortWithStatusJSON(http.StatusInternalServerError, err)
 		return
 	}
 
-	cal, err := a.getCleanedCalendar(allEvents, map[string]bool{})
+	groups, err := parseGroups(ctx.Request.URL.Query())
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	courses, err := a.listCourses(allEvents, hidden, groups, parseColorOverrides(ctx.QueryArray("color")))
 	if err != nil {
 		ctx.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
+	ctx.JSON(http.StatusOK, courses)
+}
+
+// listCourses returns the courses of the raw calendar by their name without a language, with the chosen groups and colors.
+// The conflicts are listed for the courses that are not hidden, in the chosen groups.
+func (a *App) listCourses(allEvents []byte, hidden map[string]bool, groups map[string][]string, colors map[string]string) (map[string]Course, error) {
+	cal, err := ics.ParseCalendar(bytes.NewReader(allEvents))
+	if err != nil {
+		return nil, err
+	}
 
 	// detect all courses, de-duplicate them by their summary (lecture name)
 	courses := make(map[string]Course)
 	for _, component := range cal.Components {
 		switch component.(type) {
 		case *ics.VEvent:
-			eventSummary := cleanEventSummary(component.(*ics.VEvent).GetProperty(ics.ComponentPropertySummary).Value)
+			originalSummary := cleanEventSummary(component.(*ics.VEvent).GetProperty(ics.ComponentPropertySummary).Value)
+			eventSummary := cleanEventSummary(a.courseName(originalSummary))
 			if _, exists := courses[eventSummary]; !exists {
+				// the original summary still contains the module id, which identifies the course best
+				metadata := a.lookupCourse(originalSummary)
+				color, ok := colors[eventSummary]
+				if !ok {
+					color = defaultColor(eventSummary, metadata)
+				}
 				courses[eventSummary] = Course{
 					Summary: eventSummary,
+					Title:   cleanEventSummary(a.shortenSummary(originalSummary)),
 					// Check for existing hidden course, that might want to be updated
-					Hide: hidden[eventSummary],
+					Hide:         hidden[eventSummary],
+					Color:        color,
+					Metadata:     metadata,
+					ChosenGroups: groups[eventSummary],
+				}
+			}
+			// TUMonline lists all exercise groups of a course, so let the user choose theirs
+			if group := eventGroup(originalSummary); group != "" {
+				course := courses[eventSummary]
+				if !slices.Contains(course.Groups, group) {
+					course.Groups = append(course.Groups, group)
+					courses[eventSummary] = course
 				}
 			}
 			log.Printf("summaries: %s", eventSummary)
@@ -269,13 +466,51 @@ func (a *App) handleGetCourses(ctx *gin.Context) {
 		}
 	}
 
-	ctx.JSON(http.StatusOK, courses)
+	for summary, course := range courses {
+		if len(course.Groups) < 2 {
+			course.Groups = nil
+		}
+		sortGroups(course.Groups)
+		courses[summary] = course
+	}
+
+	var filters []eventFilter
+	if len(groups) > 0 {
+		filters = append(filters, groupFilter(groups))
+	}
+	_, events, err := a.getCleanedEvents(allEvents, hidden, filters...)
+	if err != nil {
+		return nil, err
+	}
+	titles := make(map[string]string, len(events))
+	for _, e := range events {
+		titles[e.UID] = e.course
+	}
+	for _, e := range events {
+		course, ok := courses[e.course]
+		if !ok {
+			continue
+		}
+		for _, uid := range e.Conflicts {
+			if other := titles[uid]; other != "" && other != course.Summary && !slices.Contains(course.Conflicts, other) {
+				course.Conflicts = append(course.Conflicts, other)
+			}
+		}
+		courses[course.Summary] = course
+	}
+	return courses, nil
+}
+
+func (a *App) getCleanedCalendar(all []byte, hiddenCourses map[string]bool, filters ...eventFilter) (*ics.Calendar, error) {
+	cal, _, err := a.getCleanedEvents(all, hiddenCourses, filters...)
+	return cal, err
 }
 
-func (a *App) getCleanedCalendar(all []byte, hiddenCourses map[string]bool) (*ics.Calendar, error) {
+// getCleanedEvents cleans the calendar like getCleanedCalendar, but also returns the structured form of each kept event
+func (a *App) getCleanedEvents(all []byte, hiddenCourses map[string]bool, filters ...eventFilter) (*ics.Calendar, []*Event, error) {
 	cal, err := ics.ParseCalendar(strings.NewReader(string(all)))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// First pass: collect all locations for each dedup key (lecture name + datetime)
@@ -299,6 +534,8 @@ func (a *App) getCleanedCalendar(all []byte, hiddenCourses map[string]bool) (*ic
 	// Second pass: deduplicate and clean events, adding additional rooms to the description
 	hasLecture := make(map[string]bool)
 	var newComponents []ics.Component // saves the components we keep because they are not duplicated
+	var events []*Event
+	var eventComponents []*ics.VEvent // the components of events, in the same order
 
 	for _, component := range cal.Components {
 		switch component.(type) {
@@ -336,19 +573,54 @@ func (a *App) getCleanedCalendar(all []byte, hiddenCourses map[string]bool) (*ic
 			}
 
 			// clean up the event (with additional locations for the description)
-			a.cleanEvent(event, additionalLocations)
+			e := a.cleanEvent(event, additionalLocations)
+			if !keepEvent(e, filters) {
+				continue
+			}
+			events = append(events, e)
+			eventComponents = append(eventComponents, event)
 			newComponents = append(newComponents, event)
 		default: // keep everything that is not an event (metadata etc.)
 			newComponents = append(newComponents, component)
 		}
 	}
 	cal.Components = newComponents
-	return cal, nil
+
+	// Overlapping events are only noticed in the first week otherwise, so point them out
+	detectConflicts(events)
+	annotateConflicts(eventComponents, events, a.lang)
+	return cal, events, nil
 }
 
-// matches tags like (IN0001) or [MA2012] and everything after.
+// schoolPrefixes are the module code prefixes of all TUM schools and departments (see schools.json), e.g. "IN|MA|…"
+var schoolPrefixes = mustParseSchoolPrefixes(schoolsJson)
+
+// matches tags like (IN0001), [MA2012] or (IN0012, IN2106, IN4308) and everything after.
 // unfortunate also matches wrong brackets like [MA123) but hey…
-var reTag = regexp.MustCompile(" ?[\\[(](ED|MW|SOM|CIT|MA|IN|WI|WIB)[0-9]+((_|-|,)[a-zA-Z0-9]+)*[\\])].*")
+var reTag = regexp.MustCompile(" ?[\\[(](" + schoolPrefixes + ")[0-9]+((_|-|, ?)[a-zA-Z0-9]+)*[\\])].*")
+
+// matches the module codes inside a tag, e.g. IN0012 and IN2106 in (IN0012, IN2106)
+var reModuleCode = regexp.MustCompile("(?:" + schoolPrefixes + ")[0-9]+")
+
+// mustParseSchoolPrefixes returns the prefixes of schools.json as a regex alternation.
+// Longer prefixes come first, so e.g. WIB is tried before WI.
+func mustParseSchoolPrefixes(raw string) string {
+	var schools map[string]string
+	if err := json.Unmarshal([]byte(raw), &schools); err != nil {
+		panic(fmt.Sprintf("invalid schools.json: %v", err))
+	}
+	var prefixes []string
+	for prefix := range schools {
+		prefixes = append(prefixes, regexp.QuoteMeta(prefix))
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		if len(prefixes[i]) != len(prefixes[j]) {
+			return len(prefixes[i]) > len(prefixes[j])
+		}
+		return prefixes[i] < prefixes[j]
+	})
+	return strings.Join(prefixes, "|")
+}
 
 // Matches location and teacher from language course title
 var reLoc = regexp.MustCompile(" ?(München|Garching|Weihenstephan).+")
@@ -372,70 +644,88 @@ var unneeded = []string{
 	"(Online)",
 }
 
+// componentPropertyModule holds one module code (e.g. IN0004) of the course an event belongs to
+const componentPropertyModule = ics.ComponentProperty("X-TUM-MODULE")
+
 var reRoom = regexp.MustCompile("^(.*?),.*?(\\d{4})\\.(?:\\d\\d|EG|UG|DG|Z\\d|U\\d)\\.\\d+")
 
 // matches strings like: (5612.03.017), (5612.EG.017), (5612.EG.010B)
 var reNavigaTUM = regexp.MustCompile("\\(\\d{4}\\.[a-zA-Z0-9]{2}\\.\\d{3}[A-Z]?\\)")
 
-func (a *App) cleanEvent(event *ics.VEvent, additionalLocations []string) {
+func (a *App) cleanEvent(event *ics.VEvent, additionalLocations []string) *Event {
+	e := &Event{UID: event.Id(), AdditionalRooms: additionalLocations}
+
 	// Event Title
-	summary := ""
 	if s := event.GetProperty(ics.ComponentPropertySummary); s != nil {
-		summary = cleanEventSummary(s.Value)
+		e.OriginalTitle = cleanEventSummary(s.Value)
 	}
-	originalSummary := summary
+	e.Title = a.shortenSummary(e.OriginalTitle)
+	e.course = cleanEventSummary(a.courseName(e.OriginalTitle))
+	e.Type = a.eventType(e.OriginalTitle)
+	e.Group = eventGroup(e.OriginalTitle)
+	event.SetSummary(e.Title)
 
-	// Remove the TAG and anything after e.g.: (IN0001) or [MA0001]
-	summary = reTag.ReplaceAllString(summary, "")
-	// remove location and teacher from the language course title
-	summary = reLoc.ReplaceAllString(summary, "")
-	summary = reSpace.ReplaceAllString(summary, "")
-	for _, replace := range unneeded {
-		summary = strings.ReplaceAll(summary, replace, "")
-	}
-	// sometimes the summary has weird numbers attached like "0000002467 " in "0000002467 Semantik"
-	// What the heck? And why only sometimes???
-	summary = reWeirdStartingNumbers.ReplaceAllString(summary, "")
+	// Color
+	// Give every course a stable color, so clients supporting RFC 7986 can tell courses apart
+	e.Color = defaultColor(e.course, a.lookupCourse(e.OriginalTitle))
+	event.SetColor(e.Color)
 
-	// Do all the course-specific replacements
-	for _, repl := range a.courseReplacements {
-		summary = strings.ReplaceAll(summary, repl.key, repl.value)
+	// Module codes
+	// The tag is stripped from the title, but the module codes identify the course most reliably, so keep them
+	e.ModuleCodes = a.moduleCodes(e.OriginalTitle)
+	for _, code := range e.ModuleCodes {
+		event.AddCategory(code)
+		event.AddProperty(componentPropertyModule, code)
 	}
-	event.SetSummary(summary)
-
-	// Description
-	// Remember the old title in the description
-	description := ""
-	if d := event.GetProperty(ics.ComponentPropertyDescription); d != nil {
-		description = d.Value
-	}
-	description = originalSummary + "\n" + description
 
 	// Location
 	// Replace the location with the building name, if it matches our map
-	location := ""
 	if l := event.GetProperty(ics.ComponentPropertyLocation); l != nil {
-		location = event.GetProperty(ics.ComponentPropertyLocation).Value
+		e.Location = l.Value
 	}
-	results := reRoom.FindStringSubmatch(location)
+	results := reRoom.FindStringSubmatch(e.Location)
 	if len(results) == 3 {
-		if building, ok := a.buildingReplacements[results[2]]; ok {
-			description = location + "\n" + description
-			event.SetLocation(building)
+		e.Building = a.buildingReplacements[results[2]]
+		e.Campus = a.campuses[results[2]]
+		for _, roomID := range reNavigaTUM.FindAllString(e.Location, -1) {
+			roomID = strings.Trim(roomID, "()")
+			e.RoomIDs = append(e.RoomIDs, roomID)
+			e.NavLinks = append(e.NavLinks, fmt.Sprintf("https://nav.tum.de/room/%s", roomID))
 		}
-		if roomIDs := reNavigaTUM.FindAllString(location, -1); len(roomIDs) > 0 {
-			for _, roomID := range roomIDs {
-				roomID = strings.Trim(roomID, "()")
-				description = fmt.Sprintf("https://nav.tum.de/room/%s\n%s", roomID, description)
-			}
+	}
+	if e.Building != "" {
+		event.SetLocation(e.Building)
+	}
+
+	if d := event.GetProperty(ics.ComponentPropertyDescription); d != nil {
+		e.Description = d.Value
+	}
+
+	// Exams look like any other event once the title is shortened, so tag them
+	e.Exam = isExam(e.OriginalTitle)
+	if e.Exam {
+		event.AddCategory("Exam")
+	}
+
+	// Online meetings
+	// The meeting links are buried in the description, so offer them as URL and CONFERENCE to get a join button.
+	// The URL to TUMonline moves to the description instead.
+	if u := event.GetProperty(ics.ComponentPropertyUrl); u != nil {
+		e.URL = u.Value
+	}
+	e.Meeting, e.MeetingURL = findMeeting(e.Location, e.Description)
+	if e.MeetingURL != "" {
+		if replacesOnlineLocation(e) {
+			event.SetLocation(a.lang.label("online", e.Meeting))
 		}
+		setConference(event, e.Meeting, e.MeetingURL)
 	}
 
-	// Add additional locations from deduplicated events to the description
-	if len(additionalLocations) > 0 {
-		description = "Additional rooms:\n" + strings.Join(additionalLocations, "\n") + "\n\n" + description
+	// Description
+	// Remember the old title, location and everything else that was replaced in the description
+	if description, err := renderDescription(descriptionTemplates[defaultDescription], e, a.lang); err == nil {
+		event.SetDescription(description)
 	}
-	event.SetDescription(description)
 
 	// Set status based on ical status, so cancelled events are marked as such in the calendar
 	switch event.GetProperty(ics.ComponentPropertyStatus).Value {
@@ -446,6 +736,44 @@ func (a *App) cleanEvent(event *ics.VEvent, additionalLocations []string) {
 	case "TENTATIVE":
 		event.SetStatus(ics.ObjectStatusTentative)
 	}
+	e.Status = event.GetProperty(ics.ComponentPropertyStatus).Value
+
+	if start, err := event.GetStartAt(); err == nil {
+		e.Start = start.In(tumLocation)
+	}
+	if end, err := event.GetEndAt(); err == nil {
+		e.End = end.In(tumLocation)
+	}
+	return e
+}
+
+// shortenSummary strips tags, clutter and known course names from a summary
+func (a *App) shortenSummary(summary string) string {
+	// Remove the TAG and anything after e.g.: (IN0001) or [MA0001]
+	summary = reTag.ReplaceAllString(summary, "")
+	// remove location and teacher from the language course title
+	summary = reLoc.ReplaceAllString(summary, "")
+	summary = reSpace.ReplaceAllString(summary, "")
+	for _, replace := range unneeded {
+		summary = strings.ReplaceAll(summary, replace, "")
+	}
+	// sometimes the summary has weird numbers attached like "0000002467 " in "0000002467 Semantik"
+	// What the heck? And why only sometimes???
+	summary = reWeirdStartingNumbers.ReplaceAllString(summary, "")
+
+	// Do all the course-specific replacements
+	for _, repl := range a.courseReplacements {
+		summary = strings.ReplaceAll(summary, repl.key, repl.value)
+	}
+	return summary
+}
+
+// courseName shortens the summary like shortenSummary without a language, as the course list names the courses
+func (a *App) courseName(summary string) string {
+	if a.base != nil {
+		return a.base.shortenSummary(summary)
+	}
+	return a.shortenSummary(summary)
 }
 
 func cleanEventSummary(eventSummary string) string {
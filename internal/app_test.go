@@ -204,3 +204,124 @@ func TestCourseFiltering(t *testing.T) {
 		return
 	}
 }
+
+func TestModuleCodes(t *testing.T) {
+	testData, app := getTestData(t, "timeadjustment.ics")
+	calendar, err := app.getCleanedCalendar([]byte(testData), map[string]bool{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	event := calendar.Components[0].(*ics.VEvent)
+	summary := event.GetProperty(ics.ComponentPropertySummary).Value
+	if strings.Contains(summary, "IN0012") {
+		t.Errorf("Summary should not contain the module tag but is %s", summary)
+		return
+	}
+
+	var categories, modules []string
+	for _, p := range event.GetProperties(ics.ComponentPropertyCategories) {
+		categories = append(categories, p.Value)
+	}
+	for _, p := range event.GetProperties(componentPropertyModule) {
+		modules = append(modules, p.Value)
+	}
+	expected := "IN0012,IN2106,IN4308"
+	if strings.Join(categories, ",") != expected {
+		t.Errorf("Categories should be %s but are %v", expected, categories)
+	}
+	if strings.Join(modules, ",") != expected {
+		t.Errorf("X-TUM-MODULE should be %s but is %v", expected, modules)
+	}
+}
+
+func TestTagStripping(t *testing.T) {
+	testData, app := getTestData(t, "tagstripping.ics")
+	calendar, err := app.getCleanedCalendar([]byte(testData), map[string]bool{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// one event per school, plus the malformed brackets we accept and a bracket that is no tag
+	expected := map[string]struct {
+		summary string
+		module  string
+	}{
+		"890000000@tum.de": {"Experimentalphysik 1", "PH0001"},
+		"890000001@tum.de": {"Allgemeine und Anorganische Chemie", "CH1090"},
+		"890000002@tum.de": {"Digitaltechnik", "EI10001"},
+		"890000003@tum.de": {"Biochemie", "LS10001"},
+		"890000004@tum.de": {"Botanik", "WZ1600"},
+		"890000005@tum.de": {"Sportbiologie", "SG120001"},
+		"890000006@tum.de": {"Entwerfen und Konstruieren", "AR20001"},
+		"890000007@tum.de": {"Englisch - Academic Writing C1", "SZ0401"},
+		"890000008@tum.de": {"KR", "WIB02001"},
+		"890000009@tum.de": {"VWL 1", "WI000021"},
+		"890000010@tum.de": {"Machine Learning for Graphs", "CIT4230003"},
+		"890000011@tum.de": {"Technische Dynamik", "MW0034"},
+		"890000012@tum.de": {"Baustoffkunde", "ED110001"},
+		"890000013@tum.de": {"Marketing", "SOM0001"},
+		"890000014@tum.de": {"Technik und Gesellschaft", "SOT82100"},
+		"890000015@tum.de": {"Anatomie", "ME1001"},
+		"890000016@tum.de": {"Baustatik", "BV000004"},
+		"890000017@tum.de": {"Bioökonomie", "CS0001"},
+		"890000018@tum.de": {"Analysis 1", "MA9201"},
+		"890000019@tum.de": {"Algorithmik", "IN2003"},
+		"890000020@tum.de": {"Englisch (B2)", ""},
+		"890000021@tum.de": {"Hydromechanik", "BGU12345"},
+		"890000022@tum.de": {"Ethik und Verantwortung", "CLA20001"},
+		"890000023@tum.de": {"Pädagogische Psychologie", "EDU0001"},
+		"890000024@tum.de": {"Physiologie", "MED1101"},
+		"890000025@tum.de": {"Organische Chemie", "NAT0101"},
+		"890000026@tum.de": {"Politische Theorie", "POL20002"},
+	}
+	if len(calendar.Components) != len(expected) {
+		t.Errorf("Calendar should have %d entries but has %d", len(expected), len(calendar.Components))
+		return
+	}
+	for _, component := range calendar.Components {
+		event := component.(*ics.VEvent)
+		want := expected[event.Id()]
+		summary := event.GetProperty(ics.ComponentPropertySummary).Value
+		if summary != want.summary {
+			t.Errorf("Summary of %s should be %s but is %s", event.Id(), want.summary, summary)
+		}
+		module := ""
+		if m := event.GetProperty(componentPropertyModule); m != nil {
+			module = m.Value
+		}
+		if module != want.module {
+			t.Errorf("Module of %s should be %s but is %s", event.Id(), want.module, module)
+		}
+	}
+}
+
+func TestListCoursesIgnoresLanguage(t *testing.T) {
+	testData, app := getTestData(t, "groups.ics")
+	english := app.forLanguage(languages["en"])
+	courses, err := english.listCourses([]byte(testData), map[string]bool{}, map[string][]string{"Ü ERA": {"1"}}, map[string]string{"Ü ERA": "hotpink"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exercise, ok := courses["Ü ERA"]
+	if !ok {
+		t.Fatalf("Courses should be listed by their name without a language but got %v", courses)
+	}
+	if exercise.Title != "Ex ERA" {
+		t.Errorf("The title should be in English but is %q", exercise.Title)
+	}
+	if exercise.Color != "hotpink" || len(exercise.ChosenGroups) != 1 || exercise.ChosenGroups[0] != "1" {
+		t.Errorf("The color and group of the parameters should apply but got %s and %v", exercise.Color, exercise.ChosenGroups)
+	}
+
+	_, events, err := english.getCleanedEvents([]byte(testData), map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range events {
+		if course := courses[e.course]; e.course != "Ü ERA" && course.Color != e.Color {
+			t.Errorf("%s should have the color of the feed %s in the list but has %s", e.course, e.Color, course.Color)
+		}
+	}
+}
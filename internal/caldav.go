@@ -0,0 +1,388 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	ics "github.com/arran4/golang-ical"
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+)
+
+// The CalDAV interface serves the cleaned calendar as a read-only collection below caldavRoot.
+// Clients log in with the pStud (or "pers:<pPers>") as username and the pToken as password.
+const (
+	caldavRoot       = "/caldav/"
+	caldavCollection = caldavRoot + "calendar/"
+)
+
+type davMultistatus struct {
+	XMLName   xml.Name      `xml:"DAV: multistatus"`
+	Responses []davResponse `xml:"response"`
+}
+
+type davResponse struct {
+	Href     string       `xml:"href"`
+	Propstat *davPropstat `xml:"propstat,omitempty"`
+	Status   string       `xml:"status,omitempty"`
+}
+
+type davPropstat struct {
+	Prop   davProp `xml:"prop"`
+	Status string  `xml:"status"`
+}
+
+type davProp struct {
+	ResourceType         *davResourceType `xml:"resourcetype,omitempty"`
+	DisplayName          string           `xml:"displayname,omitempty"`
+	CurrentUserPrincipal *davHref         `xml:"current-user-principal,omitempty"`
+	PrivilegeSet         *davPrivilegeSet `xml:"current-user-privilege-set,omitempty"`
+	ETag                 string           `xml:"getetag,omitempty"`
+	ContentType          string           `xml:"getcontenttype,omitempty"`
+	CalendarHomeSet      *davHref         `xml:"urn:ietf:params:xml:ns:caldav calendar-home-set,omitempty"`
+	ComponentSet         *davComponentSet `xml:"urn:ietf:params:xml:ns:caldav supported-calendar-component-set,omitempty"`
+	CalendarData         string           `xml:"urn:ietf:params:xml:ns:caldav calendar-data,omitempty"`
+	CTag                 string           `xml:"http://calendarserver.org/ns/ getctag,omitempty"`
+}
+
+type davResourceType struct {
+	Collection *struct{} `xml:"collection,omitempty"`
+	Calendar   *struct{} `xml:"urn:ietf:params:xml:ns:caldav calendar,omitempty"`
+}
+
+type davHref struct {
+	// the namespace has to be repeated, as the href of calendar-home-set would be in the CalDAV namespace otherwise
+	Href string `xml:"DAV: href"`
+}
+
+type davPrivilegeSet struct {
+	Privileges []davPrivilege `xml:"privilege"`
+}
+
+type davPrivilege struct {
+	Read *struct{} `xml:"read"`
+}
+
+type davComponentSet struct {
+	Components []davComponent `xml:"urn:ietf:params:xml:ns:caldav comp"`
+}
+
+type davComponent struct {
+	Name string `xml:"name,attr"`
+}
+
+// davEvent is a single event of the collection, served as its own calendar object resource
+type davEvent struct {
+	Href  string
+	ETag  string
+	Data  string
+	Start time.Time
+	End   time.Time
+}
+
+// davReport is the part of a REPORT request we understand: calendar-query with an optional time-range and calendar-multiget
+type davReport struct {
+	Name  string
+	Hrefs []string
+	Start time.Time
+	End   time.Time
+}
+
+var readOnly = &davPrivilegeSet{Privileges: []davPrivilege{{Read: &struct{}{}}}}
+
+// caldavCredentials returns the TUMonline URL for the basic auth credentials, falling back to the usual query parameters
+func caldavCredentials(ctx *gin.Context) string {
+	if calendarURL := getCalendarURL(ctx); calendarURL != "" {
+		return calendarURL
+	}
+	username, password, ok := ctx.Request.BasicAuth()
+	if !ok || username == "" || password == "" {
+		return ""
+	}
+	if pers, ok := strings.CutPrefix(username, "pers:"); ok {
+		return tumOnlineURL("", pers, password)
+	}
+	return tumOnlineURL(username, "", password)
+}
+
+// matches the DTSTAMP of events, which TUMonline sets to the time of the export and the proxy to the time of the request
+var reDtStamp = regexp.MustCompile(`(?m)^DTSTAMP[:;][^\r\n]*\r?\n`)
+
+// eventTag changes whenever the event changes, but not with its DTSTAMP, which changes on every fetch
+func eventTag(data string) string {
+	sum := sha256.Sum256([]byte(reDtStamp.ReplaceAllString(data, "")))
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// splitCalendar serves every event of the calendar as its own resource, with the calendar properties (and timezones) of the original
+func splitCalendar(cal *ics.Calendar) []davEvent {
+	var others []ics.Component
+	for _, component := range cal.Components {
+		if _, ok := component.(*ics.VEvent); !ok {
+			others = append(others, component)
+		}
+	}
+
+	var events []davEvent
+	for _, event := range cal.Events() {
+		single := ics.NewCalendar()
+		single.CalendarProperties = cal.CalendarProperties
+		single.Components = append(append([]ics.Component{}, others...), event)
+		data := single.Serialize()
+		e := davEvent{
+			Href: caldavCollection + url.PathEscape(event.Id()) + ".ics",
+			ETag: eventTag(data),
+			Data: data,
+		}
+		e.Start, _ = event.GetStartAt()
+		e.End, _ = event.GetEndAt()
+		events = append(events, e)
+	}
+	return events
+}
+
+// collectionTag changes whenever any event of the collection changes
+func collectionTag(events []davEvent) string {
+	h := sha256.New()
+	for _, e := range events {
+		h.Write([]byte(e.Href + e.ETag))
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:8]) + `"`
+}
+
+// parseReport reads the type of report, the requested hrefs and the time-range of a REPORT body
+func parseReport(body io.Reader) (davReport, error) {
+	var report davReport
+	decoder := xml.NewDecoder(body)
+	inHref := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return report, nil
+		}
+		if err != nil {
+			return report, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if report.Name == "" {
+				report.Name = t.Name.Local
+			}
+			inHref = t.Name.Space == "DAV:" && t.Name.Local == "href"
+			if t.Name.Local == "time-range" {
+				for _, attr := range t.Attr {
+					value, err := time.Parse("20060102T150405Z", attr.Value)
+					if err != nil {
+						return report, err
+					}
+					switch attr.Name.Local {
+					case "start":
+						report.Start = value
+					case "end":
+						report.End = value
+					}
+				}
+			}
+		case xml.CharData:
+			if inHref {
+				report.Hrefs = append(report.Hrefs, strings.TrimSpace(string(t)))
+			}
+		case xml.EndElement:
+			inHref = false
+		}
+	}
+}
+
+// inTimeRange reports whether the event overlaps the time-range of the report, unknown bounds match everything
+func (r davReport) inTimeRange(e davEvent) bool {
+	if !r.Start.IsZero() && !e.End.IsZero() && !e.End.After(r.Start) {
+		return false
+	}
+	if !r.End.IsZero() && !e.Start.IsZero() && !e.Start.Before(r.End) {
+		return false
+	}
+	return true
+}
+
+func eventResponse(e davEvent, withData bool) davResponse {
+	prop := davProp{ETag: e.ETag, ContentType: "text/calendar; charset=utf-8; component=VEVENT"}
+	if withData {
+		prop.CalendarData = e.Data
+	}
+	return davResponse{Href: e.Href, Propstat: &davPropstat{Prop: prop, Status: "HTTP/1.1 200 OK"}}
+}
+
+func collectionResponse(events []davEvent) davResponse {
+	return davResponse{Href: caldavCollection, Propstat: &davPropstat{Status: "HTTP/1.1 200 OK", Prop: davProp{
+		ResourceType:         &davResourceType{Collection: &struct{}{}, Calendar: &struct{}{}},
+		DisplayName:          "TUM",
+		CurrentUserPrincipal: &davHref{Href: caldavRoot},
+		PrivilegeSet:         readOnly,
+		ComponentSet:         &davComponentSet{Components: []davComponent{{Name: "VEVENT"}}},
+		CTag:                 collectionTag(events),
+	}}}
+}
+
+func rootResponse() davResponse {
+	return davResponse{Href: caldavRoot, Propstat: &davPropstat{Status: "HTTP/1.1 200 OK", Prop: davProp{
+		ResourceType:         &davResourceType{Collection: &struct{}{}},
+		DisplayName:          "TUM Calendar Proxy",
+		CurrentUserPrincipal: &davHref{Href: caldavRoot},
+		CalendarHomeSet:      &davHref{Href: caldavRoot},
+		PrivilegeSet:         readOnly,
+	}}}
+}
+
+// propfind answers PROPFIND for the principal (which also is the calendar home), the collection and single events.
+// All properties we know are returned regardless of the request, which clients are fine with.
+func propfind(resource string, depth string, events []davEvent) (davMultistatus, bool) {
+	var multistatus davMultistatus
+	switch resource {
+	case caldavRoot:
+		multistatus.Responses = append(multistatus.Responses, rootResponse())
+		if depth != "0" {
+			multistatus.Responses = append(multistatus.Responses, collectionResponse(events))
+		}
+	case caldavCollection:
+		multistatus.Responses = append(multistatus.Responses, collectionResponse(events))
+		if depth != "0" {
+			for _, e := range events {
+				multistatus.Responses = append(multistatus.Responses, eventResponse(e, false))
+			}
+		}
+	default:
+		for _, e := range events {
+			if e.Href == resource {
+				multistatus.Responses = append(multistatus.Responses, eventResponse(e, false))
+			}
+		}
+		if len(multistatus.Responses) == 0 {
+			return multistatus, false
+		}
+	}
+	return multistatus, true
+}
+
+// report answers calendar-query and calendar-multiget REPORTs on the collection
+func report(r davReport, events []davEvent) (davMultistatus, bool) {
+	var multistatus davMultistatus
+	switch r.Name {
+	case "calendar-query":
+		for _, e := range events {
+			if r.inTimeRange(e) {
+				multistatus.Responses = append(multistatus.Responses, eventResponse(e, true))
+			}
+		}
+	case "calendar-multiget":
+		byHref := make(map[string]davEvent, len(events))
+		for _, e := range events {
+			byHref[e.Href] = e
+		}
+		for _, href := range r.Hrefs {
+			if e, ok := byHref[davPath(href)]; ok {
+				multistatus.Responses = append(multistatus.Responses, eventResponse(e, true))
+			} else {
+				multistatus.Responses = append(multistatus.Responses, davResponse{Href: href, Status: "HTTP/1.1 404 Not Found"})
+			}
+		}
+	default:
+		return multistatus, false
+	}
+	return multistatus, true
+}
+
+// davPath normalizes hrefs, which clients send as absolute URLs or paths with differing escaping
+func davPath(href string) string {
+	if u, err := url.Parse(href); err == nil {
+		href = u.EscapedPath()
+	}
+	dir, file := path.Split(href)
+	if unescaped, err := url.PathUnescape(file); err == nil {
+		file = url.PathEscape(unescaped)
+	}
+	return dir + file
+}
+
+func writeMultistatus(ctx *gin.Context, multistatus davMultistatus) {
+	response, err := xml.Marshal(multistatus)
+	if err != nil {
+		sentry.CaptureException(err)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	ctx.Data(http.StatusMultiStatus, "application/xml; charset=utf-8", append([]byte(xml.Header), response...))
+}
+
+// handleCalDAV serves the cleaned calendar as a read-only CalDAV collection.
+func (a *App) handleCalDAV(ctx *gin.Context) {
+	ctx.Header("DAV", "1, calendar-access")
+	ctx.Header("Allow", "OPTIONS, GET, HEAD, PROPFIND, REPORT")
+	switch ctx.Request.Method {
+	case http.MethodOptions:
+		ctx.Status(http.StatusOK)
+		return
+	case http.MethodGet, http.MethodHead, "PROPFIND", "REPORT":
+	default:
+		ctx.AbortWithStatus(http.StatusForbidden) // read-only
+		return
+	}
+
+	calendarURL := caldavCredentials(ctx)
+	if calendarURL == "" {
+		ctx.Header("WWW-Authenticate", `Basic realm="TUM Calendar Proxy"`)
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	cleaned, _, ok := a.requestFeed(ctx, calendarURL)
+	if !ok {
+		return
+	}
+	events := splitCalendar(cleaned)
+
+	resource := davPath(ctx.Request.URL.Path)
+	if resource+"/" == caldavRoot || resource+"/" == caldavCollection {
+		resource += "/"
+	}
+	switch ctx.Request.Method {
+	case http.MethodGet, http.MethodHead:
+		if resource == caldavCollection {
+			ctx.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(cleaned.Serialize()))
+			return
+		}
+		for _, e := range events {
+			if e.Href == resource {
+				ctx.Header("ETag", e.ETag)
+				ctx.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(e.Data))
+				return
+			}
+		}
+		ctx.AbortWithStatus(http.StatusNotFound)
+	case "PROPFIND":
+		multistatus, ok := propfind(resource, ctx.GetHeader("Depth"), events)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		writeMultistatus(ctx, multistatus)
+	case "REPORT":
+		r, err := parseReport(io.LimitReader(ctx.Request.Body, 1<<20))
+		if err != nil {
+			ctx.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		multistatus, ok := report(r, events)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusNotImplemented)
+			return
+		}
+		writeMultistatus(ctx, multistatus)
+	}
+}
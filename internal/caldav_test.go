@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func getTestDAVEvents(t *testing.T) []davEvent {
+	testData, app := getTestData(t, "tagstripping.ics")
+	cleaned, err := app.getCleanedCalendar([]byte(testData), map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := splitCalendar(cleaned)
+	if len(events) != len(cleaned.Events()) {
+		t.Fatalf("Every event should be its own resource, got %d of %d", len(events), len(cleaned.Events()))
+	}
+	return events
+}
+
+func TestSplitCalendar(t *testing.T) {
+	events := getTestDAVEvents(t)
+	for _, e := range events {
+		if !strings.HasPrefix(e.Href, caldavCollection) || !strings.HasSuffix(e.Href, ".ics") {
+			t.Errorf("Unexpected href %s", e.Href)
+		}
+		if strings.Count(e.Data, "BEGIN:VEVENT") != 1 {
+			t.Errorf("Resource %s should contain exactly one event", e.Href)
+		}
+	}
+	if events[0].ETag == events[1].ETag {
+		t.Error("Different events should have different ETags")
+	}
+	if getTestDAVEvents(t)[0].ETag != events[0].ETag {
+		t.Error("ETags should be stable between fetches")
+	}
+	// TUMonline sets DTSTAMP to the time of the export, so the same event fetched later only differs in it
+	testData, app := getTestData(t, "tagstripping.ics")
+	later := regexp.MustCompile(`DTSTAMP:\d{8}T\d{6}Z`).ReplaceAllString(testData, "DTSTAMP:20991231T235959Z")
+	cleaned, err := app.getCleanedCalendar([]byte(later), map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	refetched := splitCalendar(cleaned)
+	if refetched[0].Data == events[0].Data {
+		t.Fatal("The refetched event should have another DTSTAMP")
+	}
+	if refetched[0].ETag != events[0].ETag || collectionTag(refetched) != collectionTag(events) {
+		t.Error("ETags should not change with the DTSTAMP")
+	}
+}
+
+func TestPropfind(t *testing.T) {
+	events := getTestDAVEvents(t)
+	multistatus, ok := propfind(caldavCollection, "1", events)
+	if !ok || len(multistatus.Responses) != len(events)+1 {
+		t.Fatalf("Expected the collection and all events but got %d responses", len(multistatus.Responses))
+	}
+	raw, err := xml.Marshal(multistatus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{`<multistatus xmlns="DAV:">`, `<calendar xmlns="urn:ietf:params:xml:ns:caldav"></calendar>`, `<getctag xmlns="http://calendarserver.org/ns/">`, "<getetag>"} {
+		if !strings.Contains(string(raw), expected) {
+			t.Errorf("PROPFIND response should contain %s", expected)
+		}
+	}
+	multistatus, _ = propfind(caldavRoot, "0", events)
+	if len(multistatus.Responses) != 1 || multistatus.Responses[0].Propstat.Prop.CalendarHomeSet == nil {
+		t.Fatal("Principal should point to its calendar home")
+	}
+	if raw, err = xml.Marshal(multistatus); err != nil {
+		t.Fatal(err)
+	}
+	// the href of the calendar home is a DAV element inside a CalDAV one
+	if expected := `<calendar-home-set xmlns="urn:ietf:params:xml:ns:caldav"><href xmlns="DAV:">` + caldavRoot + `</href></calendar-home-set>`; !strings.Contains(string(raw), expected) {
+		t.Errorf("PROPFIND response should contain %s but is %s", expected, raw)
+	}
+	if _, ok := propfind(caldavCollection+"missing.ics", "0", events); ok {
+		t.Error("Unknown events should not be found")
+	}
+}
+
+func TestReport(t *testing.T) {
+	events := getTestDAVEvents(t)
+
+	query, err := parseReport(strings.NewReader(`<?xml version="1.0"?>
+<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop><D:getetag/><C:calendar-data/></D:prop>
+  <C:filter><C:comp-filter name="VCALENDAR"><C:comp-filter name="VEVENT">
+    <C:time-range start="20000101T000000Z" end="20000102T000000Z"/>
+  </C:comp-filter></C:comp-filter></C:filter>
+</C:calendar-query>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query.Name != "calendar-query" || !query.Start.Equal(time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected report %v", query)
+	}
+	if multistatus, _ := report(query, events); len(multistatus.Responses) != 0 {
+		t.Errorf("No events should be in the year 2000 but got %d", len(multistatus.Responses))
+	}
+	query.Start, query.End = time.Time{}, time.Time{}
+	if multistatus, _ := report(query, events); len(multistatus.Responses) != len(events) {
+		t.Errorf("Query without time-range should return all events but got %d", len(multistatus.Responses))
+	}
+
+	multiget, err := parseReport(strings.NewReader(`<?xml version="1.0"?>
+<C:calendar-multiget xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop><D:getetag/><C:calendar-data/></D:prop>
+  <D:href>https://cal.tum.app` + events[0].Href + `</D:href>
+  <D:href>` + caldavCollection + `missing.ics</D:href>
+</C:calendar-multiget>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	multistatus, ok := report(multiget, events)
+	if !ok || len(multistatus.Responses) != 2 {
+		t.Fatalf("Expected a response per href but got %v", multistatus.Responses)
+	}
+	if found := multistatus.Responses[0]; found.Propstat == nil || found.Propstat.Prop.CalendarData != events[0].Data {
+		t.Error("Multiget should return the calendar data of the event")
+	}
+	if missing := multistatus.Responses[1]; missing.Status != "HTTP/1.1 404 Not Found" {
+		t.Errorf("Unknown hrefs should be reported as missing but got %v", missing)
+	}
+}
+
+func TestCalDAVReadOnly(t *testing.T) {
+	_, app := getTestData(t, "tagstripping.ics")
+	gin.SetMode(gin.TestMode)
+	app.engine = gin.New()
+	app.configRoutes()
+
+	for method, expected := range map[string]int{http.MethodOptions: http.StatusOK, "PROPFIND": http.StatusUnauthorized, http.MethodPut: http.StatusForbidden} {
+		w := httptest.NewRecorder()
+		app.engine.ServeHTTP(w, httptest.NewRequest(method, caldavCollection, nil))
+		if w.Code != expected {
+			t.Errorf("%s should answer %d but got %d", method, expected, w.Code)
+		}
+		if !strings.Contains(w.Header().Get("DAV"), "calendar-access") {
+			t.Errorf("%s should announce CalDAV support", method)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package internal
+
+import (
+	_ "embed"
+	"encoding/json"
+	"regexp"
+	"time"
+)
+
+//go:embed campuses.json
+var campusesJson string
+
+// campusData maps the postcodes of the buildings to campuses, and lists the travel time between them
+type campusData struct {
+	Campuses      map[string][]string `json:"campuses"`
+	TravelMinutes []struct {
+		From    string `json:"from"`
+		To      string `json:"to"`
+		Minutes int    `json:"minutes"`
+	} `json:"travelMinutes"`
+}
+
+// matches the postcode of addresses like "Boltzmannstr. 10, 85748 Garching b. München"
+var rePostcode = regexp.MustCompile(`\b(\d{5})\b[^,]*$`)
+
+// parseCampuses returns the campus of every building with a known address and the travel times between the campuses
+func parseCampuses(raw []byte, buildings map[string]string) (map[string]string, map[[2]string]time.Duration, error) {
+	var data campusData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, nil, err
+	}
+
+	campusOfPostcode := make(map[string]string)
+	for campus, postcodes := range data.Campuses {
+		for _, postcode := range postcodes {
+			campusOfPostcode[postcode] = campus
+		}
+	}
+	campuses := make(map[string]string)
+	for building, address := range buildings {
+		if results := rePostcode.FindStringSubmatch(address); len(results) == 2 && campusOfPostcode[results[1]] != "" {
+			campuses[building] = campusOfPostcode[results[1]]
+		}
+	}
+
+	travelTimes := make(map[[2]string]time.Duration)
+	for _, travel := range data.TravelMinutes {
+		duration := time.Duration(travel.Minutes) * time.Minute
+		travelTimes[[2]string{travel.From, travel.To}] = duration
+		travelTimes[[2]string{travel.To, travel.From}] = duration
+	}
+	return campuses, travelTimes, nil
+}
+
+// travelTime returns how long it takes to get from one campus to another, zero if either is unknown
+func (a *App) travelTime(from string, to string) time.Duration {
+	if from == "" || to == "" || from == to {
+		return 0
+	}
+	return a.travelTimes[[2]string{from, to}]
+}
@@ -0,0 +1,266 @@
+package internal
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+)
+
+// maxChanges is the number of changes kept per subscription, older ones are dropped
+const maxChanges = 200
+
+// types of changes between two fetches of a calendar
+const (
+	changeAdded     = "added"
+	changeRemoved   = "removed"
+	changeMoved     = "moved"
+	changeRoom      = "room"
+	changeCancelled = "cancelled"
+)
+
+// snapshotEvent is the part of an event we remember between fetches to detect changes
+type snapshotEvent struct {
+	UID      string    `json:"uid"`
+	Title    string    `json:"title"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Location string    `json:"location"`
+	Status   string    `json:"status"`
+}
+
+// Change is a difference between two fetches of the same calendar
+type Change struct {
+	Type             string    `json:"type"`
+	DetectedAt       time.Time `json:"detectedAt"`
+	UID              string    `json:"uid"`
+	Title            string    `json:"title"`
+	Start            time.Time `json:"start"`
+	End              time.Time `json:"end"`
+	PreviousStart    time.Time `json:"previousStart,omitzero"`
+	PreviousEnd      time.Time `json:"previousEnd,omitzero"`
+	Location         string    `json:"location"`
+	PreviousLocation string    `json:"previousLocation,omitempty"`
+}
+
+func newSnapshot(events []*Event) []snapshotEvent {
+	snapshot := make([]snapshotEvent, 0, len(events))
+	for _, e := range events {
+		snapshot = append(snapshot, snapshotEvent{UID: e.UID, Title: e.Title, Start: e.Start, End: e.End, Location: e.Location, Status: e.Status})
+	}
+	return snapshot
+}
+
+// diffEvents compares two snapshots of a calendar. Events that were already over at now are ignored,
+// as TUMonline drops old semesters and nobody cares about a room change last year.
+func diffEvents(old []snapshotEvent, current []snapshotEvent, now time.Time) []Change {
+	previous := make(map[string]snapshotEvent, len(old))
+	for _, e := range old {
+		previous[e.UID] = e
+	}
+
+	var changes []Change
+	seen := make(map[string]bool, len(current))
+	for _, e := range current {
+		seen[e.UID] = true
+		change := Change{DetectedAt: now, UID: e.UID, Title: e.Title, Start: e.Start, End: e.End, Location: e.Location}
+		before, ok := previous[e.UID]
+		if !ok {
+			if e.End.After(now) && e.Status != "CANCELLED" {
+				change.Type = changeAdded
+				changes = append(changes, change)
+			}
+			continue
+		}
+		if !e.End.After(now) && !before.End.After(now) {
+			continue
+		}
+		if e.Status == "CANCELLED" && before.Status != "CANCELLED" {
+			change.Type = changeCancelled
+			changes = append(changes, change)
+			continue
+		}
+		if !e.Start.Equal(before.Start) || !e.End.Equal(before.End) {
+			change.Type = changeMoved
+			change.PreviousStart = before.Start
+			change.PreviousEnd = before.End
+			changes = append(changes, change)
+		}
+		if e.Location != before.Location {
+			change.Type = changeRoom
+			change.PreviousStart, change.PreviousEnd = time.Time{}, time.Time{}
+			change.PreviousLocation = before.Location
+			changes = append(changes, change)
+		}
+	}
+
+	for _, e := range old {
+		if !seen[e.UID] && e.End.After(now) && e.Status != "CANCELLED" {
+			changes = append(changes, Change{Type: changeRemoved, DetectedAt: now, UID: e.UID, Title: e.Title, Start: e.Start, End: e.End, Location: e.Location})
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Start.Before(changes[j].Start) })
+	return changes
+}
+
+// trackChanges compares the events with the last snapshot of the subscription, stores the new snapshot and
+// returns the changes detected so far, newest first. Without a store, change tracking is disabled.
+func (a *App) trackChanges(id string, events []*Event, now time.Time) ([]Change, error) {
+	if a.store == nil {
+		return nil, nil
+	}
+	// concurrent fetches of the same feed would otherwise both diff against the same snapshot and report everything twice
+	defer a.store.Lock("snapshots", id)()
+
+	var old []snapshotEvent
+	known, err := a.store.Load("snapshots", id, &old)
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	if _, err := a.store.Load("changes", id, &changes); err != nil {
+		return nil, err
+	}
+
+	current := newSnapshot(events)
+	if known {
+		// on the first fetch everything would be new, which is not worth reporting
+		if detected := diffEvents(old, current, now); len(detected) > 0 {
+			a.notifyWebhooks(id, detected)
+			changes = append(detected, changes...)
+			changes = changes[:min(len(changes), maxChanges)]
+			if err := a.store.Save("changes", id, changes); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := a.store.Save("snapshots", id, current); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// changesID identifies the change history of a feed. Hidden courses are part of it, as feeds hiding different
+// courses of the same calendar would otherwise report the difference as added and removed events.
+// The other parameters don't change the history, see feedChanges.
+func changesID(ctx *gin.Context) string {
+	return feedID(getCalendarURL(ctx), ctx.QueryArray("hide"))
+}
+
+func feedID(calendarURL string, hidden []string) string {
+	hidden = slices.Clone(hidden)
+	slices.Sort(hidden)
+	return subscriptionID(calendarURL + "\n" + strings.Join(hidden, "\n"))
+}
+
+// describeChange returns a human-readable summary of the change, e.g. for feed readers
+func describeChange(c Change) string {
+	const layout = "Mon 02.01.2006 15:04"
+	start := c.Start.In(tumLocation).Format(layout)
+	switch c.Type {
+	case changeAdded:
+		return fmt.Sprintf("%s on %s was added", c.Title, start)
+	case changeRemoved:
+		return fmt.Sprintf("%s on %s was removed", c.Title, start)
+	case changeCancelled:
+		return fmt.Sprintf("%s on %s was cancelled", c.Title, start)
+	case changeMoved:
+		return fmt.Sprintf("%s moved from %s to %s", c.Title, c.PreviousStart.In(tumLocation).Format(layout), start)
+	case changeRoom:
+		return fmt.Sprintf("%s on %s moved from %s to %s", c.Title, start, c.PreviousLocation, c.Location)
+	}
+	return fmt.Sprintf("%s on %s changed", c.Title, start)
+}
+
+// changesFor fetches the requested calendar, so the change history is up to date even if no client refreshed it recently
+func (a *App) changesFor(ctx *gin.Context) ([]Change, bool) {
+	if a.store == nil {
+		ctx.AbortWithStatusJSON(http.StatusNotImplemented, gin.H{"error": "change tracking is disabled"})
+		return nil, false
+	}
+
+	calendarURL := getUrl(ctx)
+	if calendarURL == "" {
+		return nil, false
+	}
+	options, err := parseFeedOptions(ctx.Request.URL.Query(), time.Now())
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	_, _, changes, err := a.fetchFeed(calendarURL, options, time.Now())
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, err)
+		return nil, false
+	}
+	return changes, true
+}
+
+// handleGetChanges returns the detected changes of a calendar as JSON, newest first.
+func (a *App) handleGetChanges(ctx *gin.Context) {
+	changes, ok := a.changesFor(ctx)
+	if !ok {
+		return
+	}
+	if changes == nil {
+		changes = []Change{}
+	}
+	ctx.JSON(http.StatusOK, changes)
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID      string `xml:"id"`
+	Title   string `xml:"title"`
+	Updated string `xml:"updated"`
+	Summary string `xml:"summary"`
+}
+
+// buildAtomFeed renders the changes as an Atom feed. The ids are derived from the subscription, so no token leaks.
+func buildAtomFeed(id string, changes []Change, now time.Time) atomFeed {
+	feed := atomFeed{
+		ID:      "urn:tum-calendar-proxy:changes:" + id,
+		Title:   "TUM calendar changes",
+		Updated: now.UTC().Format(time.RFC3339),
+	}
+	if len(changes) > 0 {
+		feed.Updated = changes[0].DetectedAt.UTC().Format(time.RFC3339)
+	}
+	for _, c := range changes {
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      fmt.Sprintf("%s:%s:%s:%d", feed.ID, c.UID, c.Type, c.DetectedAt.Unix()),
+			Title:   describeChange(c),
+			Updated: c.DetectedAt.UTC().Format(time.RFC3339),
+			Summary: fmt.Sprintf("%s (%s)", describeChange(c), c.Location),
+		})
+	}
+	return feed
+}
+
+// handleChangesFeed returns the detected changes of a calendar as an Atom feed for feed readers.
+func (a *App) handleChangesFeed(ctx *gin.Context) {
+	changes, ok := a.changesFor(ctx)
+	if !ok {
+		return
+	}
+	response, err := xml.MarshalIndent(buildAtomFeed(changesID(ctx), changes, time.Now()), "", "  ")
+	if err != nil {
+		sentry.CaptureException(err)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	ctx.Data(http.StatusOK, "application/atom+xml; charset=utf-8", append([]byte(xml.Header), response...))
+}
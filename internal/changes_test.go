@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDiffEvents(t *testing.T) {
+	now := time.Date(2024, time.January, 10, 12, 0, 0, 0, tumLocation)
+	at := func(day int, hour int) time.Time {
+		return time.Date(2024, time.January, day, hour, 0, 0, 0, tumLocation)
+	}
+	old := []snapshotEvent{
+		{UID: "past", Title: "ERA", Start: at(8, 10), End: at(8, 12), Location: "MI HS 1"},
+		{UID: "moved", Title: "GAD", Start: at(11, 10), End: at(11, 12), Location: "MI HS 1"},
+		{UID: "room", Title: "Theo", Start: at(12, 10), End: at(12, 12), Location: "MI HS 1"},
+		{UID: "cancelled", Title: "DS", Start: at(12, 14), End: at(12, 16), Location: "MI HS 2", Status: "CONFIRMED"},
+		{UID: "removed", Title: "EIST", Start: at(15, 10), End: at(15, 12), Location: "MW 0001"},
+	}
+	current := []snapshotEvent{
+		{UID: "past", Title: "ERA", Start: at(8, 10), End: at(8, 12), Location: "MI HS 2"},
+		{UID: "moved", Title: "GAD", Start: at(11, 14), End: at(11, 16), Location: "MI HS 1"},
+		{UID: "room", Title: "Theo", Start: at(12, 10), End: at(12, 12), Location: "MI HS 3"},
+		{UID: "cancelled", Title: "DS", Start: at(12, 14), End: at(12, 16), Location: "MI HS 2", Status: "CANCELLED"},
+		{UID: "added", Title: "NumProg", Start: at(16, 10), End: at(16, 12), Location: "MW 2001"},
+	}
+
+	changes := diffEvents(old, current, now)
+	expected := []string{"moved:moved", "room:room", "cancelled:cancelled", "removed:removed", "added:added"}
+	if len(changes) != len(expected) {
+		t.Fatalf("Expected %d changes but got %v", len(expected), changes)
+	}
+	for i, c := range changes {
+		if got := c.UID + ":" + c.Type; got != expected[i] {
+			t.Errorf("Change %d should be %s but is %s", i, expected[i], got)
+		}
+	}
+	if !changes[0].PreviousStart.Equal(at(11, 10)) {
+		t.Errorf("Moved event should remember the previous start but has %v", changes[0].PreviousStart)
+	}
+	if changes[1].PreviousLocation != "MI HS 1" {
+		t.Errorf("Room change should remember the previous room but has %s", changes[1].PreviousLocation)
+	}
+	if description := describeChange(changes[1]); !strings.Contains(description, "MI HS 1 to MI HS 3") {
+		t.Errorf("Room change is described as %s", description)
+	}
+}
+
+func TestTrackChanges(t *testing.T) {
+	testData, app := getTestData(t, "location.ics")
+	store, err := newStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.store = store
+
+	_, events, err := app.getCleanedEvents([]byte(testData), map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := events[0].Start.Add(-time.Hour)
+	if changes, err := app.trackChanges("test", events, now); err != nil || len(changes) != 0 {
+		t.Errorf("First fetch should not report changes but got %v (err %v)", changes, err)
+	}
+
+	events[0].Location = "Somewhere else"
+	changes, err := app.trackChanges("test", events, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Type != changeRoom {
+		t.Errorf("Expected one room change but got %v", changes)
+	}
+	if changes, _ := app.trackChanges("test", events, now); len(changes) != 1 {
+		t.Errorf("Unchanged fetch should keep the history but got %v", changes)
+	}
+}
+
+func TestBuildAtomFeed(t *testing.T) {
+	now := time.Date(2024, time.January, 10, 12, 0, 0, 0, tumLocation)
+	changes := []Change{{Type: changeCancelled, DetectedAt: now, UID: "1", Title: "ERA", Start: now, End: now.Add(time.Hour)}}
+	feed := buildAtomFeed("abc", changes, now)
+	if len(feed.Entries) != 1 || !strings.Contains(feed.Entries[0].Title, "ERA on Wed 10.01.2024 12:00 was cancelled") {
+		t.Errorf("Unexpected feed entries %v", feed.Entries)
+	}
+	if !strings.HasPrefix(feed.Entries[0].ID, feed.ID) {
+		t.Errorf("Entry id %s should be derived from the feed id %s", feed.Entries[0].ID, feed.ID)
+	}
+}
+
+func TestTrackChangesConcurrently(t *testing.T) {
+	testData, app := getTestData(t, "location.ics")
+	store, err := newStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.store = store
+
+	_, events, err := app.getCleanedEvents([]byte(testData), map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := events[0].Start.Add(-time.Hour)
+	if _, err := app.trackChanges("test", events, now); err != nil {
+		t.Fatal(err)
+	}
+
+	events[0].Location = "Somewhere else"
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := app.trackChanges("test", events, now); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var changes []Change
+	if _, err := store.Load("changes", "test", &changes); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 {
+		t.Errorf("Concurrent fetches should report the room change once but got %v", changes)
+	}
+}
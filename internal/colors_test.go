@@ -0,0 +1,56 @@
+package internal
+
+import (
+	"testing"
+
+	ics "github.com/arran4/golang-ical"
+)
+
+func TestDefaultColor(t *testing.T) {
+	color := defaultColor("ERA TÜ", nil)
+	if color != defaultColor("ERA TÜ ", nil) {
+		t.Error("Default color should be deterministic and ignore surrounding whitespace")
+	}
+	if _, ok := cssColors[color]; !ok {
+		t.Errorf("Default color should be a CSS3 color name but is %s", color)
+	}
+	if color := defaultColor("ERA TÜ", &CourseMetadata{Color: "darkorange"}); color != "darkorange" {
+		t.Errorf("Color from courses.json should be preferred but got %s", color)
+	}
+}
+
+func TestParseColorOverrides(t *testing.T) {
+	overrides := parseColorOverrides([]string{"ERA:Tomato", "Analysis: teal", "Theo:#ff0000", "GBS:foo", "broken", ":red"})
+	if len(overrides) != 2 {
+		t.Errorf("Expected 2 valid overrides but got %v", overrides)
+	}
+	if overrides["ERA"] != "tomato" {
+		t.Errorf("Color should be normalized to tomato but is %s", overrides["ERA"])
+	}
+	if overrides["Analysis"] != "teal" {
+		t.Errorf("Color should be teal but is %s", overrides["Analysis"])
+	}
+}
+
+func TestColorOverrides(t *testing.T) {
+	testData, app := getTestData(t, "coursefiltering.ics")
+	calendar, events, err := app.getCleanedEvents([]byte(testData), map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, event := range calendar.Events() {
+		if event.GetProperty(ics.ComponentPropertyColor) == nil {
+			t.Fatalf("Event %s should have a default color", event.Id())
+		}
+	}
+
+	applyColorOverrides(calendar, events, map[string]string{"ERA": "hotpink"})
+	for _, event := range calendar.Events() {
+		color := event.GetProperty(ics.ComponentPropertyColor).Value
+		if summary := event.GetProperty(ics.ComponentPropertySummary).Value; summary == "ERA" && color != "hotpink" {
+			t.Errorf("ERA should be hotpink but is %s", color)
+		} else if summary != "ERA" && color == "hotpink" {
+			t.Errorf("%s should keep its default color", summary)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// limits of /api/common-free, as every subscription has to be fetched from TUMonline
+const (
+	maxCommonFreeSubscriptions = 10
+	maxCommonFreeDays          = 62
+	defaultCommonFreeDays      = 7
+)
+
+// travelSlot is the time needed to get to an event from the previous event on another campus
+type travelSlot struct {
+	timeInterval
+	From string
+	To   string
+	// Before is the UID of the event the travel leads to
+	Before string
+}
+
+// travelSlots returns the time needed to get to an event from the previous event of the same day on another campus
+func (a *App) travelSlots(events []*Event) []travelSlot {
+	var travel []travelSlot
+	var previous *Event
+	for _, e := range filterEvents(events, time.Time{}, time.Time{}, nil) {
+		if e.Status == "CANCELLED" {
+			continue
+		}
+		if previous != nil && startOfDay(previous.End.In(tumLocation)).Equal(startOfDay(e.Start.In(tumLocation))) {
+			if duration := a.travelTime(previous.Campus, e.Campus); duration > 0 {
+				start := e.Start.Add(-duration)
+				if start.Before(previous.End) {
+					start = previous.End
+				}
+				travel = append(travel, travelSlot{timeInterval: timeInterval{Start: start, End: e.Start}, From: previous.Campus, To: e.Campus, Before: e.UID})
+			}
+		}
+		if previous == nil || e.End.After(previous.End) {
+			previous = e
+		}
+	}
+	return travel
+}
+
+// travelIntervals blocks the time needed to change campuses between events
+func (a *App) travelIntervals(events []*Event) []timeInterval {
+	var intervals []timeInterval
+	for _, travel := range a.travelSlots(events) {
+		intervals = append(intervals, travel.timeInterval)
+	}
+	return intervals
+}
+
+// parseClock parses times of day like 08:00 as the duration since midnight
+func parseClock(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// freeSlots returns the gaps of at least minDuration between the busy intervals, within [dayStart, dayEnd) of every day in [from, to)
+func freeSlots(busy []timeInterval, from time.Time, to time.Time, dayStart time.Duration, dayEnd time.Duration, minDuration time.Duration) []timeInterval {
+	var free []timeInterval
+	for day := startOfDay(from.In(tumLocation)); day.Before(to); day = day.AddDate(0, 0, 1) {
+		// the hours are added to the date, so days with a daylight saving time change are still right
+		windowStart := time.Date(day.Year(), day.Month(), day.Day(), int(dayStart.Hours()), int(dayStart.Minutes())%60, 0, 0, tumLocation)
+		windowEnd := time.Date(day.Year(), day.Month(), day.Day(), int(dayEnd.Hours()), int(dayEnd.Minutes())%60, 0, 0, tumLocation)
+		if windowStart.Before(from) {
+			windowStart = from
+		}
+		if windowEnd.After(to) {
+			windowEnd = to
+		}
+
+		start := windowStart
+		for _, b := range mergeIntervals(busy, windowStart, windowEnd) {
+			if b.Start.Sub(start) >= minDuration {
+				free = append(free, timeInterval{Start: start, End: b.Start})
+			}
+			start = b.End
+		}
+		if windowEnd.Sub(start) >= minDuration {
+			free = append(free, timeInterval{Start: start, End: windowEnd})
+		}
+	}
+	return free
+}
+
+// handleCommonFree returns the slots in which all of several stored subscriptions are free.
+// It takes subscription=<id> (repeated), from and to (default the next week), dayStart and dayEnd (default 08:00 to 20:00),
+// minDuration in minutes (default 60) and travel=true to also block the time needed to change campuses.
+func (a *App) handleCommonFree(ctx *gin.Context) {
+	if a.store == nil {
+		ctx.AbortWithStatusJSON(http.StatusNotImplemented, gin.H{"error": "storage is disabled"})
+		return
+	}
+	ids := ctx.QueryArray("subscription")
+	if len(ids) == 0 || len(ids) > maxCommonFreeSubscriptions {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("1 to %d subscriptions are required", maxCommonFreeSubscriptions)})
+		return
+	}
+
+	from, to, ok := parseRange(ctx)
+	if !ok {
+		return
+	}
+	if from.IsZero() {
+		from = startOfDay(time.Now().In(tumLocation))
+	}
+	if to.IsZero() {
+		to = from.AddDate(0, 0, defaultCommonFreeDays)
+	}
+	if !to.After(from) || to.After(from.AddDate(0, 0, maxCommonFreeDays)) {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("the range has to span 1 to %d days", maxCommonFreeDays)})
+		return
+	}
+
+	dayStart, errStart := parseClock(ctx.DefaultQuery("dayStart", "08:00"))
+	dayEnd, errEnd := parseClock(ctx.DefaultQuery("dayEnd", "20:00"))
+	if errStart != nil || errEnd != nil || dayEnd <= dayStart {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid dayStart or dayEnd"})
+		return
+	}
+	minutes, err := strconv.Atoi(ctx.DefaultQuery("minDuration", "60"))
+	if err != nil || minutes <= 0 {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid minDuration"})
+		return
+	}
+	travel := ctx.Query("travel") == "true"
+
+	var busy []timeInterval
+	for _, id := range ids {
+		if !reStoreKey.MatchString(id) {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "unknown subscription " + id})
+			return
+		}
+		events, known, err := a.loadSubscriptionEvents(id, time.Now())
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("can't load subscription %s: %v", id, err)})
+			return
+		}
+		if !known {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "unknown subscription " + id})
+			return
+		}
+		busy = append(busy, busyIntervals(events, from, to)...)
+		if travel {
+			busy = append(busy, a.travelIntervals(events)...)
+		}
+	}
+
+	free := freeSlots(busy, from, to, dayStart, dayEnd, time.Duration(minutes)*time.Minute)
+	if free == nil {
+		free = []timeInterval{}
+	}
+	ctx.JSON(http.StatusOK, gin.H{"from": from, "to": to, "free": free})
+}
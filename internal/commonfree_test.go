@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCampuses(t *testing.T) {
+	_, app := getTestData(t, "location.ics")
+	if campus := app.campuses["5602"]; campus != "Garching" {
+		t.Errorf("Building 5602 should be in Garching but is in %q", campus)
+	}
+	if campus := app.campuses["0501"]; campus != "Stammgelände" {
+		t.Errorf("Building 0501 should be on the Stammgelände but is on %q", campus)
+	}
+	if app.travelTime("Garching", "Stammgelände") != app.travelTime("Stammgelände", "Garching") || app.travelTime("Garching", "Stammgelände") == 0 {
+		t.Error("Travel times should be known in both directions")
+	}
+	if app.travelTime("Garching", "Garching") != 0 || app.travelTime("", "Garching") != 0 {
+		t.Error("Travel on the same or an unknown campus should take no time")
+	}
+}
+
+func TestFreeSlots(t *testing.T) {
+	_, app := getTestData(t, "location.ics")
+	day := time.Date(2024, time.January, 9, 0, 0, 0, 0, tumLocation)
+	events := []*Event{
+		testEvent("ERA", day.Add(8*time.Hour), 120),
+		testEvent("GAD", day.Add(12*time.Hour), 90),
+		testEvent("Theo", day.Add(15*time.Hour), 60),
+	}
+	events[0].Campus = "Stammgelände"
+	events[1].Campus = "Garching"
+	events[2].Campus = "Garching"
+
+	travel := app.travelIntervals(events)
+	if len(travel) != 1 || !travel[0].End.Equal(day.Add(12*time.Hour)) || !travel[0].Start.Equal(day.Add(12*time.Hour).Add(-app.travelTime("Stammgelände", "Garching"))) {
+		t.Errorf("Expected the travel to Garching before GAD but got %v", travel)
+	}
+
+	busy := append(busyIntervals(events, day, day.AddDate(0, 0, 1)), travel...)
+	free := freeSlots(busy, day, day.AddDate(0, 0, 1), 8*time.Hour, 18*time.Hour, time.Hour)
+	expected := []timeInterval{
+		{Start: day.Add(10 * time.Hour), End: day.Add(11*time.Hour + 20*time.Minute)},
+		{Start: day.Add(13*time.Hour + 30*time.Minute), End: day.Add(15 * time.Hour)},
+		{Start: day.Add(16 * time.Hour), End: day.Add(18 * time.Hour)},
+	}
+	if len(free) != len(expected) {
+		t.Fatalf("Expected %d free slots but got %v", len(expected), free)
+	}
+	for i := range expected {
+		if !free[i].Start.Equal(expected[i].Start) || !free[i].End.Equal(expected[i].End) {
+			t.Errorf("Free slot %d should be %v but is %v", i, expected[i], free[i])
+		}
+	}
+}
+
+func TestCommonFree(t *testing.T) {
+	calendar, err := os.ReadFile("testdata/location.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tumOnline := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write(calendar) }))
+	defer tumOnline.Close()
+
+	app := newWebhookTestApp(t)
+	gin.SetMode(gin.TestMode)
+	app.engine = gin.New()
+	app.configRoutes()
+	for _, id := range []string{"alice", "bob"} {
+		if err := app.store.Save("subscriptions", id, Subscription{CalendarURL: tumOnline.URL}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	request := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		app.engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/common-free?"+query, nil))
+		return w
+	}
+	if w := request("subscription=alice&subscription=unknown"); w.Code != http.StatusNotFound {
+		t.Errorf("Unknown subscriptions should not be found but got %d", w.Code)
+	}
+	w := request("subscription=alice&subscription=bob&from=2023-01-13&to=2023-01-14&dayStart=08:00&dayEnd=18:00")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Request failed with %d: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		Free []timeInterval `json:"free"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	// both have the same event from 13:00 to 15:00 local time
+	if len(response.Free) != 2 || response.Free[0].End.Hour() != 13 || response.Free[1].Start.Hour() != 15 {
+		t.Errorf("Unexpected free slots %v", response.Free)
+	}
+}
+
+func TestNewSubscription(t *testing.T) {
+	id, s, err := newSubscription(testContext("pStud=ABCDEF&pToken=SECRET&hide=ERA&group=GAD:3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Options.Get("pToken") != "" || s.Options.Get("group") != "GAD:3" {
+		t.Errorf("The subscription should keep the feed parameters without the token but has %v", s.Options)
+	}
+	if same, _, _ := newSubscription(testContext("group=GAD:3&hide=ERA&pToken=SECRET&pStud=ABCDEF")); same != id {
+		t.Error("The same feed should get the same id")
+	}
+	if other, _, _ := newSubscription(testContext("pStud=ABCDEF&pToken=SECRET&hide=ERA&group=GAD:4")); other == id {
+		t.Error("Feeds with different parameters should get different ids")
+	}
+	if _, _, err := newSubscription(testContext("pStud=ABCDEF&pToken=SECRET&after=noon")); err == nil {
+		t.Error("Invalid parameters should be rejected")
+	}
+}
+
+func TestCommonFreeKeepsGroups(t *testing.T) {
+	calendar, err := os.ReadFile("testdata/groups.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tumOnline := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write(calendar) }))
+	defer tumOnline.Close()
+
+	app := newWebhookTestApp(t)
+	// the exercise groups are from 9 to 11 and from 15 to 17, the lecture from 13 to 15 local time
+	subscription := Subscription{CalendarURL: tumOnline.URL, Options: url.Values{"group": {"IN0004:1"}}}
+	if err := app.store.Save("subscriptions", "alice", subscription); err != nil {
+		t.Fatal(err)
+	}
+	events, known, err := app.loadSubscriptionEvents("alice", time.Now())
+	if err != nil || !known {
+		t.Fatalf("Can't load the subscription: %v", err)
+	}
+	from := time.Date(2023, time.January, 13, 8, 0, 0, 0, tumLocation)
+	busy := busyIntervals(events, from, from.Add(10*time.Hour))
+	if len(busy) != 2 || busy[0].Start.Hour() != 9 || busy[1].End.Hour() != 15 {
+		t.Errorf("Only the chosen exercise group and the lecture should be busy but got %v", busy)
+	}
+}
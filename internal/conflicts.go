@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	ics "github.com/arran4/golang-ical"
+)
+
+// componentPropertyConflict holds the UID of an event overlapping with this one, once per overlapping event
+const componentPropertyConflict = ics.ComponentProperty("X-TUM-CONFLICT")
+
+// conflictMarker is prepended to the summary of overlapping events if requested
+const conflictMarker = "⚠ "
+
+// detectConflicts sets the conflicts of all non-cancelled events that overlap with each other
+func detectConflicts(events []*Event) {
+	var active []*Event
+	for _, e := range events {
+		if e.Status != "CANCELLED" {
+			active = append(active, e)
+		}
+	}
+	sort.SliceStable(active, func(i, j int) bool { return active[i].Start.Before(active[j].Start) })
+
+	// sweep over the events by start, remembering the ones that have not ended yet
+	var running []*Event
+	for _, e := range active {
+		stillRunning := running[:0]
+		for _, other := range running {
+			if other.End.After(e.Start) {
+				stillRunning = append(stillRunning, other)
+			}
+		}
+		running = stillRunning
+		for _, other := range running {
+			if e.End.After(e.Start) {
+				other.Conflicts = append(other.Conflicts, e.UID)
+				other.overlapping = append(other.overlapping, e)
+				e.Conflicts = append(e.Conflicts, other.UID)
+				e.overlapping = append(e.overlapping, other)
+			}
+		}
+		running = append(running, e)
+	}
+}
+
+// annotateConflicts adds the conflicts of the events to their components, which are in the same order.
+// Their descriptions are rendered again, as the description templates point out the overlapping events.
+func annotateConflicts(components []*ics.VEvent, events []*Event, lang *language) {
+	for i, e := range events {
+		if len(e.Conflicts) == 0 {
+			continue
+		}
+		for _, uid := range e.Conflicts {
+			components[i].AddProperty(componentPropertyConflict, uid)
+		}
+		if description, err := renderDescription(descriptionTemplates[defaultDescription], e, lang); err == nil {
+			components[i].SetDescription(description)
+		}
+	}
+}
+
+// describeConflicts lists the events overlapping with e, like "GAD (10:00-12:00)"
+func describeConflicts(e *Event) []string {
+	var overlaps []string
+	for _, other := range e.overlapping {
+		overlaps = append(overlaps, fmt.Sprintf("%s (%s-%s)", strings.TrimSpace(other.Title), other.Start.In(tumLocation).Format("15:04"), other.End.In(tumLocation).Format("15:04")))
+	}
+	return overlaps
+}
+
+// markConflicts prefixes the summaries of overlapping events with a warning, so they stand out in the calendar
+func markConflicts(cal *ics.Calendar) {
+	for _, event := range cal.Events() {
+		if event.GetProperty(componentPropertyConflict) == nil {
+			continue
+		}
+		if s := event.GetProperty(ics.ComponentPropertySummary); s != nil && !strings.HasPrefix(s.Value, conflictMarker) {
+			event.SetSummary(conflictMarker + s.Value)
+		}
+	}
+}
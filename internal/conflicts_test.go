@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	ics "github.com/arran4/golang-ical"
+)
+
+func TestDetectConflicts(t *testing.T) {
+	day := time.Date(2024, time.January, 9, 0, 0, 0, 0, tumLocation)
+	events := []*Event{
+		testEvent("ERA", day.Add(8*time.Hour), 120),
+		testEvent("GAD", day.Add(9*time.Hour), 120),
+		testEvent("Theo", day.Add(10*time.Hour), 60),
+		testEvent("DS", day.Add(10*time.Hour+30*time.Minute), 60),
+		testEvent("EIST", day.Add(14*time.Hour), 60),
+	}
+	for i, e := range events {
+		e.UID = e.Title
+		if i == 3 {
+			e.Status = "CANCELLED"
+		}
+	}
+
+	detectConflicts(events)
+	expected := map[string]string{"ERA": "GAD", "GAD": "ERA,Theo", "Theo": "GAD", "DS": "", "EIST": ""}
+	for _, e := range events {
+		if got := strings.Join(e.Conflicts, ","); got != expected[e.UID] {
+			t.Errorf("%s should conflict with %q but conflicts with %q", e.UID, expected[e.UID], got)
+		}
+	}
+}
+
+func TestConflictAnnotation(t *testing.T) {
+	testData, app := getTestData(t, "tagstripping.ics")
+	cleaned, events, err := app.getCleanedEvents([]byte(testData), map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, event := range cleaned.Events() {
+		if hasConflict := event.GetProperty(componentPropertyConflict) != nil; hasConflict != (len(events[i].Conflicts) > 0) {
+			t.Errorf("%s should only be annotated if it has conflicts", events[i].UID)
+		}
+	}
+
+	cal := ics.NewCalendar()
+	event := cal.AddEvent("1")
+	event.SetSummary("ERA")
+	event.AddProperty(componentPropertyConflict, "2")
+	other := cal.AddEvent("2")
+	other.SetSummary("GAD")
+	markConflicts(cal)
+	markConflicts(cal)
+	if summary := event.GetProperty(ics.ComponentPropertySummary).Value; summary != conflictMarker+"ERA" {
+		t.Errorf("Conflicting event should be marked once but is %q", summary)
+	}
+	if summary := other.GetProperty(ics.ComponentPropertySummary).Value; summary != "GAD" {
+		t.Errorf("Other events should not be marked but got %q", summary)
+	}
+}
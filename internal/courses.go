@@ -0,0 +1,109 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// CourseMetadata describes a course we know about from courses.json.
+// Entries in courses.json are either a plain shortened name (legacy format)
+// or an object with the fields below, keyed by the long course name.
+// Color has to be a CSS3 color name, as RFC 7986 demands for the COLOR property.
+type CourseMetadata struct {
+	ModuleID  string   `json:"moduleId,omitempty"`
+	ShortName string   `json:"shortName"`
+	LongName  string   `json:"longName,omitempty"`
+	Faculty   string   `json:"faculty,omitempty"`
+	Category  string   `json:"category,omitempty"`
+	Color     string   `json:"color,omitempty"`
+	Aliases   []string `json:"aliases,omitempty"`
+}
+
+// parseCourses parses courses.json into replacements (including aliases) and the structured course metadata.
+// Plain string values are treated as simple replacements without metadata to stay compatible with the flat format.
+func parseCourses(raw []byte) ([]*Replacement, []*CourseMetadata, error) {
+	var entries map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, nil, err
+	}
+
+	var replacements []*Replacement
+	var courses []*CourseMetadata
+	for key, value := range entries {
+		var shortName string
+		if err := json.Unmarshal(value, &shortName); err == nil {
+			replacements = append(replacements, &Replacement{key: key, value: shortName})
+			continue
+		}
+
+		var course CourseMetadata
+		if err := json.Unmarshal(value, &course); err != nil {
+			return nil, nil, fmt.Errorf("invalid course %q: %w", key, err)
+		}
+		if course.LongName == "" {
+			course.LongName = key
+		}
+		replacements = append(replacements, &Replacement{key: key, value: course.ShortName})
+		for _, alias := range course.Aliases {
+			replacements = append(replacements, &Replacement{key: alias, value: course.ShortName})
+		}
+		courses = append(courses, &course)
+	}
+	return replacements, courses, nil
+}
+
+// lookupCourse returns the metadata of the course the (uncleaned) summary belongs to, or nil if it is unknown.
+// Module ids are the most reliable identifier, so they take precedence over the course names.
+func (a *App) lookupCourse(summary string) *CourseMetadata {
+	for _, code := range extractModuleCodes(summary) {
+		for _, course := range a.courses {
+			if course.ModuleID == code {
+				return course
+			}
+		}
+	}
+
+	var best *CourseMetadata
+	bestLength := 0
+	for _, course := range a.courses {
+		for _, name := range append([]string{course.LongName}, course.Aliases...) {
+			if len(name) > bestLength && strings.Contains(summary, name) {
+				best = course
+				bestLength = len(name)
+			}
+		}
+	}
+	return best
+}
+
+// extractModuleCodes returns the module codes from the tag of a summary,
+// e.g. [IN0012 IN2106 IN4308] for "Open Source Lab (IN0012, IN2106, IN4308) PR, Standardgruppe"
+func extractModuleCodes(summary string) []string {
+	tag := reTag.FindString(summary)
+	if end := strings.IndexAny(tag, ")]"); end >= 0 {
+		tag = tag[:end]
+	}
+
+	var codes []string
+	seen := make(map[string]bool)
+	for _, code := range reModuleCode.FindAllString(tag, -1) {
+		if !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// moduleCodes returns the module codes of an (uncleaned) summary.
+// If the summary has no tag, we fall back to the module id from courses.json.
+func (a *App) moduleCodes(summary string) []string {
+	if codes := extractModuleCodes(summary); len(codes) > 0 {
+		return codes
+	}
+	if course := a.lookupCourse(summary); course != nil && course.ModuleID != "" {
+		return []string{course.ModuleID}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCourses(t *testing.T) {
+	raw := `{
+  "Vorlesung": "VL",
+  "Einführung in die Rechnerarchitektur": {
+    "moduleId": "IN0004",
+    "shortName": "ERA",
+    "faculty": "CIT",
+    "aliases": ["Introduction to Computer Architecture"]
+  }
+}`
+	replacements, courses, err := parseCourses([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replacements) != 3 {
+		t.Errorf("Expected 3 replacements (flat entry, course and alias) but got %d", len(replacements))
+	}
+	if len(courses) != 1 {
+		t.Fatalf("Only structured entries should produce course metadata, got %d", len(courses))
+	}
+	if courses[0].LongName != "Einführung in die Rechnerarchitektur" {
+		t.Errorf("Long name should default to the key but is %s", courses[0].LongName)
+	}
+	for _, r := range replacements {
+		if r.key == "Introduction to Computer Architecture" && r.value != "ERA" {
+			t.Errorf("Alias should be replaced with the short name but is replaced with %s", r.value)
+		}
+	}
+
+	if _, _, err := parseCourses([]byte(`{"ERA": 42}`)); err == nil {
+		t.Error("Invalid course entries should be rejected")
+	}
+}
+
+func TestLookupCourse(t *testing.T) {
+	app, err := newApp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	course := app.lookupCourse("Einführung in die Rechnerarchitektur (IN0004) VO, Standardgruppe")
+	if course == nil || course.ShortName != "ERA" {
+		t.Fatalf("Expected ERA but got %v", course)
+	}
+	if course.Faculty != "CIT" {
+		t.Errorf("Expected faculty CIT but got %s", course.Faculty)
+	}
+
+	// the module id wins, even if the name is unknown
+	if course := app.lookupCourse("Some renamed course (IN0011) VO"); course == nil || course.ShortName != "Theo" {
+		t.Errorf("Expected Theo but got %v", course)
+	}
+
+	if course := app.lookupCourse("Kurs zum/zur Fachsanitäter*in"); course != nil {
+		t.Errorf("Flat replacements should not have metadata but got %v", course)
+	}
+}
+
+func TestExtractModuleCodes(t *testing.T) {
+	tests := map[string][]string{
+		"Einführung in die Rechnerarchitektur (IN0004) VO, Standardgruppe":              {"IN0004"},
+		"Practical Course: Open Source Lab (IN0012, IN2106, IN4308) PR, Standardgruppe": {"IN0012", "IN2106", "IN4308"},
+		"Lineare Algebra für Informatik [MA0901] VO":                                    {"MA0901"},
+		"Kurs zum/zur Fachsanitäter*in":                                                 nil,
+		"Analysis für Informatik (Fokus Analysis) (MA0902_1) ZÜ, Gruppe 01":             {"MA0902"},
+	}
+	for summary, expected := range tests {
+		codes := extractModuleCodes(summary)
+		if strings.Join(codes, ",") != strings.Join(expected, ",") {
+			t.Errorf("Module codes of %q should be %v but are %v", summary, expected, codes)
+		}
+	}
+}
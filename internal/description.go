@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"text/template"
+
+	ics "github.com/arran4/golang-ical"
+)
+
+// defaultDescription is the preset used if a feed doesn't choose one, as feeds showed this description before presets existed
+const defaultDescription = "verbose"
+
+var descriptionFuncs = template.FuncMap{"join": strings.Join}
+
+// descriptionTemplates are the presets for the description of events, selectable with description=<name>.
+// They are executed with descriptionData.
+var descriptionTemplates = map[string]*template.Template{
+	// verbose keeps everything TUMonline tells about an event
+	"verbose": template.Must(template.New("verbose").Funcs(descriptionFuncs).Parse(
+		`{{with .AdditionalRooms}}{{$.Label "additionalRooms"}}
+{{join . "\n"}}
+
+{{end}}{{range .NavLinks}}{{.}}
+{{end}}{{with .OriginalLocation}}{{.}}
+{{end}}{{.OriginalTitle}}
+{{.Description}}{{if .MeetingURL}}{{with .URL}}
+{{.}}{{end}}{{end}}{{with .Conflicts}}
+
+{{$.Label "overlapsWith" (join . ", ")}}{{end}}`)),
+	// compact only has what is needed to get to the event
+	"compact": template.Must(template.New("compact").Funcs(descriptionFuncs).Parse(
+		`{{with .MeetingURL}}{{.}}
+{{end}}{{range .NavLinks}}{{.}}
+{{end}}{{with .AdditionalRooms}}{{$.Label "additionalRooms"}} {{join . ", "}}
+{{end}}{{.OriginalTitle}}{{with .ModuleCodes}} ({{join . ", "}}){{end}}{{with .Conflicts}}
+{{$.Label "overlapsWith" (join . ", ")}}{{end}}`)),
+}
+
+// descriptionData are the fields available in description templates
+type descriptionData struct {
+	*Event
+	// OriginalLocation is the location from TUMonline, if it was replaced in the event, e.g. with the building name
+	OriginalLocation string
+	// Conflicts describe the overlapping events instead of listing their UIDs like Event.Conflicts
+	Conflicts []string
+	lang      *language
+}
+
+// Label returns the text for key in the language of the feed, formatted with args if there are any
+func (d descriptionData) Label(key string, args ...any) string {
+	return d.lang.label(key, args...)
+}
+
+func newDescriptionData(e *Event, lang *language) descriptionData {
+	data := descriptionData{Event: e, Conflicts: describeConflicts(e), lang: lang}
+	if e.Building != "" || (replacesOnlineLocation(e) && e.Location != "") {
+		data.OriginalLocation = e.Location
+	}
+	return data
+}
+
+// renderDescription executes the description template with the fields of the event
+func renderDescription(tmpl *template.Template, e *Event, lang *language) (string, error) {
+	var description strings.Builder
+	if err := tmpl.Execute(&description, newDescriptionData(e, lang)); err != nil {
+		return "", err
+	}
+	return description.String(), nil
+}
+
+// parseDescriptionTemplate returns the description preset chosen with description=<name>
+func parseDescriptionTemplate(query url.Values) (*template.Template, error) {
+	name := query.Get("description")
+	if name == "" {
+		name = defaultDescription
+	}
+	tmpl, ok := descriptionTemplates[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown description %s", name)
+	}
+	return tmpl, nil
+}
+
+// applyDescriptionTemplate renders the description of all events of the calendar with another preset
+func applyDescriptionTemplate(cal *ics.Calendar, events []*Event, tmpl *template.Template, lang *language) {
+	if tmpl == descriptionTemplates[defaultDescription] {
+		return // cleanEvent already rendered it
+	}
+	byUID := make(map[string]*Event, len(events))
+	for _, e := range events {
+		byUID[e.UID] = e
+	}
+	for _, event := range cal.Events() {
+		e, ok := byUID[event.Id()]
+		if !ok {
+			continue
+		}
+		description, err := renderDescription(tmpl, e, lang)
+		if err != nil {
+			log.Printf("can't render description of %s: %v", e.UID, err)
+			continue
+		}
+		event.SetDescription(description)
+	}
+}
@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	ics "github.com/arran4/golang-ical"
+)
+
+func TestDescriptionTemplates(t *testing.T) {
+	testData, app := getTestData(t, "location.ics")
+	cal, events, err := app.getCleanedEvents([]byte(testData), map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verbose := cal.Events()[0].GetProperty(ics.ComponentPropertyDescription).Value
+
+	applyDescriptionTemplate(cal, events, descriptionTemplates["compact"], defaultLanguage)
+	compact := cal.Events()[0].GetProperty(ics.ComponentPropertyDescription).Value
+	expected := "https://nav.tum.de/room/5508.02.801\nAdditional rooms: MI HS 1\nEinführung in die Rechnerarchitektur (IN0004) VO, Standardgruppe (IN0004)"
+	if compact != expected {
+		t.Errorf("Compact description should be \n\n%s\n\nbut is\n\n%s\n\n", expected, compact)
+	}
+	if len(compact) >= len(verbose) {
+		t.Errorf("Compact description should be shorter than %q", verbose)
+	}
+
+	if description, err := renderDescription(descriptionTemplates["verbose"], events[0], defaultLanguage); err != nil || description != verbose {
+		t.Errorf("cleanEvent should render the verbose description %q, got %q", description, verbose)
+	}
+}
+
+func TestOnlineDescription(t *testing.T) {
+	testData, app := getTestData(t, "online.ics")
+	_, events, err := app.getCleanedEvents([]byte(testData), map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	description, err := renderDescription(descriptionTemplates["compact"], events[0], defaultLanguage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(description, events[0].MeetingURL+"\n") {
+		t.Errorf("Compact description should start with the meeting link, got %q", description)
+	}
+}
+
+func TestParseDescriptionTemplate(t *testing.T) {
+	for query, name := range map[string]string{"": "verbose", "description=compact": "compact", "description=verbose": "verbose"} {
+		tmpl, err := parseDescriptionTemplate(testQuery(query))
+		if err != nil || tmpl.Name() != name {
+			t.Errorf("%q should select %s", query, name)
+		}
+	}
+	if _, err := parseDescriptionTemplate(testQuery("description={{.Title}}")); err == nil {
+		t.Errorf("Only presets should be accepted")
+	}
+}
+
+func TestDescriptionConflicts(t *testing.T) {
+	testData, app := getTestData(t, "coursefiltering.ics")
+	cal, events, err := app.getCleanedEvents([]byte(testData), map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, tmpl := range descriptionTemplates {
+		applyDescriptionTemplate(cal, events, tmpl, defaultLanguage)
+		for _, event := range cal.Events() {
+			if description := event.GetProperty(ics.ComponentPropertyDescription).Value; !strings.Contains(description, "Overlaps with: ") {
+				t.Errorf("The %s description of %s should point out the overlap but is %q", name, event.Id(), description)
+			}
+		}
+	}
+}
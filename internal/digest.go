@@ -0,0 +1,360 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+
+	ics "github.com/arran4/golang-ical"
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+)
+
+// Digest is a subscription to the weekly email with the upcoming week and the changes since the last one.
+// The calendar is fetched via the stored Subscription of the feed, as there is no request to take it from.
+type Digest struct {
+	Email        string `json:"email"`
+	Subscription string `json:"subscription"`
+	// Confirmed is set once the link in the confirmation email was opened, only confirmed digests are sent
+	Confirmed        bool      `json:"confirmed"`
+	ConfirmToken     string    `json:"confirmToken,omitempty"`
+	UnsubscribeToken string    `json:"unsubscribeToken"`
+	Created          time.Time `json:"created"`
+	// ConfirmationSent is when the confirmation email was last sent, to not send it again on every request
+	ConfirmationSent time.Time `json:"confirmationSent,omitempty"`
+	LastSent         time.Time `json:"lastSent"`
+}
+
+const (
+	// digestConfirmationTimeout is how long a digest waits for its confirmation before it is deleted
+	digestConfirmationTimeout = 7 * 24 * time.Hour
+	// digestConfirmationInterval is how long subscribing again waits before the confirmation email is sent again
+	digestConfirmationInterval = time.Hour
+)
+
+// digestID identifies the digest of a subscription for an email, so subscribing twice doesn't send two digests
+func digestID(subscription string, email string) string {
+	return subscriptionID(subscription + "\n" + strings.ToLower(email))
+}
+
+// Mailer sends emails through a SMTP server
+type Mailer struct {
+	addr      string
+	from      string
+	auth      smtp.Auth
+	publicURL string
+}
+
+// newMailerFromEnv configures the mailer via SMTP_ADDR (host:port), SMTP_FROM, SMTP_USERNAME, SMTP_PASSWORD and
+// PUBLIC_URL (for confirmation and unsubscribe links). Without SMTP_ADDR, digests are disabled and nil is returned.
+func newMailerFromEnv() *Mailer {
+	addr := os.Getenv("SMTP_ADDR")
+	if addr == "" {
+		return nil
+	}
+	m := &Mailer{addr: addr, from: os.Getenv("SMTP_FROM"), publicURL: os.Getenv("PUBLIC_URL")}
+	if m.from == "" {
+		m.from = "calendar@tum.app"
+	}
+	if m.publicURL == "" {
+		m.publicURL = "https://cal.tum.app"
+	}
+	if username := os.Getenv("SMTP_USERNAME"); username != "" {
+		host, _, _ := strings.Cut(addr, ":")
+		m.auth = smtp.PlainAuth("", username, os.Getenv("SMTP_PASSWORD"), host)
+	}
+	return m
+}
+
+// Send sends a plain text email. The unsubscribe link is also announced via List-Unsubscribe for mail clients.
+func (m *Mailer) Send(to string, subject string, body string, unsubscribeURL string) error {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", m.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "List-Unsubscribe: <%s>\r\n", unsubscribeURL)
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{to}, msg.Bytes())
+}
+
+// buildDigest renders the events of the week starting at monday and the changes as plain text
+func buildDigest(events []*Event, changes []Change, monday time.Time, unsubscribeURL string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Your week from %s\n\n", monday.Format("Monday, 02.01.2006"))
+
+	week := filterEvents(events, monday, monday.AddDate(0, 0, 7), nil)
+	if len(week) == 0 {
+		b.WriteString("No events.\n")
+	}
+	var day time.Time
+	for _, e := range week {
+		start, end := e.Start.In(tumLocation), e.End.In(tumLocation)
+		if date := startOfDay(start); !date.Equal(day) {
+			day = date
+			fmt.Fprintf(&b, "%s\n", start.Format("Mon 02.01."))
+		}
+		line := fmt.Sprintf("  %s-%s %s", start.Format("15:04"), end.Format("15:04"), strings.TrimSpace(e.Title))
+		if e.Location != "" {
+			line += " (" + e.Location + ")"
+		}
+		if e.Status == "CANCELLED" {
+			line += " - cancelled"
+		}
+		b.WriteString(line + "\n")
+	}
+
+	if len(changes) > 0 {
+		b.WriteString("\nChanges since the last digest\n")
+		for _, c := range changes {
+			fmt.Fprintf(&b, "  %s\n", describeChange(c))
+		}
+	}
+
+	fmt.Fprintf(&b, "\n--\nYou receive this email because you subscribed to the weekly digest of the TUM calendar proxy.\nUnsubscribe: %s\n", unsubscribeURL)
+	return b.String()
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// digestDue reports whether the digest should be sent at now: on sunday evening, once per week, if it was confirmed
+func digestDue(d Digest, now time.Time) bool {
+	now = now.In(tumLocation)
+	return d.Confirmed && now.Weekday() == time.Sunday && now.Hour() >= 18 && now.Sub(d.LastSent) > 24*time.Hour
+}
+
+// unsubscribeURL returns the link to stop the digest, which works without the calendar token
+func (m *Mailer) unsubscribeURL(id string, d Digest) string {
+	return fmt.Sprintf("%s/digest/unsubscribe?id=%s&token=%s", strings.TrimSuffix(m.publicURL, "/"), id, d.UnsubscribeToken)
+}
+
+// confirmURL returns the link to confirm that the owner of the email address wants the digest
+func (m *Mailer) confirmURL(id string, d Digest) string {
+	return fmt.Sprintf("%s/digest/confirm?id=%s&token=%s", strings.TrimSuffix(m.publicURL, "/"), id, d.ConfirmToken)
+}
+
+// buildConfirmation renders the email asking to confirm a new digest
+func buildConfirmation(confirmURL string, unsubscribeURL string) string {
+	return fmt.Sprintf("Please confirm that you want to receive the weekly digest of your TUM calendar:\n%s\n\n"+
+		"If you didn't subscribe, ignore this email and you won't hear from us again.\n\n--\nUnsubscribe: %s\n", confirmURL, unsubscribeURL)
+}
+
+// sendDigest fetches the calendar of the digest and mails the upcoming week with the changes since the last digest
+func (a *App) sendDigest(id string, d Digest, now time.Time) error {
+	var s Subscription
+	known, err := a.store.Load("subscriptions", d.Subscription, &s)
+	if err != nil {
+		return err
+	}
+	if !known {
+		return fmt.Errorf("subscription %s of the digest is gone", d.Subscription)
+	}
+	events, changes, err := a.subscriptionFeed(s, now)
+	if err != nil {
+		return err
+	}
+	var recent []Change
+	for _, c := range changes {
+		if c.DetectedAt.After(d.LastSent) && c.DetectedAt.After(d.Created) {
+			recent = append(recent, c)
+		}
+	}
+
+	monday := startOfWeek(now).AddDate(0, 0, 7)
+	subject := fmt.Sprintf("Your TUM week from %s", monday.Format("02.01.2006"))
+	unsubscribeURL := a.mailer.unsubscribeURL(id, d)
+	if err := a.mailer.Send(d.Email, subject, buildDigest(events, recent, monday, unsubscribeURL), unsubscribeURL); err != nil {
+		return err
+	}
+
+	d.LastSent = now
+	return a.store.Save("digests", id, d)
+}
+
+// sendDueDigests sends all digests that are due at now
+func (a *App) sendDueDigests(now time.Time) {
+	ids, err := a.store.List("digests")
+	if err != nil {
+		sentry.CaptureException(err)
+		return
+	}
+	for _, id := range ids {
+		var d Digest
+		if _, err := a.store.Load("digests", id, &d); err != nil {
+			sentry.CaptureException(err)
+			continue
+		}
+		if !d.Confirmed && now.Sub(d.Created) > digestConfirmationTimeout {
+			if err := a.store.Delete("digests", id); err != nil {
+				sentry.CaptureException(err)
+			}
+			continue
+		}
+		if !digestDue(d, now) {
+			continue
+		}
+		if err := a.sendDigest(id, d, now); err != nil {
+			log.Printf("can't send digest %s: %v", id, err)
+			sentry.CaptureException(err)
+		}
+	}
+}
+
+// scheduleDigests checks for due digests every few minutes, forever
+func (a *App) scheduleDigests() {
+	for now := range time.Tick(10 * time.Minute) {
+		a.sendDueDigests(now)
+	}
+}
+
+// handleSubscribeDigest stores a weekly digest for a feed and mails a link to confirm it, so nobody can sign up
+// someone else. The body is {"email": "..."}. Subscribing again re-sends the confirmation of the existing digest,
+// at most once per digestConfirmationInterval.
+func (a *App) handleSubscribeDigest(ctx *gin.Context) {
+	if a.store == nil || a.mailer == nil {
+		ctx.AbortWithStatusJSON(http.StatusNotImplemented, gin.H{"error": "digests are disabled"})
+		return
+	}
+	if getCalendarURL(ctx) == "" {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "pStud or pPers and pToken are required"})
+		return
+	}
+	var body struct {
+		Email string `json:"email"`
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "an email is required"})
+		return
+	}
+	address, err := mail.ParseAddress(body.Email)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid email"})
+		return
+	}
+
+	subscription, s, err := newSubscription(ctx)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// check the token once, before anything is stored or mailed
+	all, err := fetchCalendar(s.CalendarURL)
+	if err == nil {
+		_, err = ics.ParseCalendar(bytes.NewReader(all))
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "can't load the calendar, check pStud or pPers and pToken"})
+		return
+	}
+
+	now := time.Now()
+	id := digestID(subscription, address.Address)
+	defer a.store.Lock("digests", id)()
+	var d Digest
+	known, err := a.store.Load("digests", id, &d)
+	if err != nil {
+		sentry.CaptureException(err)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if known && d.Confirmed {
+		ctx.JSON(http.StatusOK, gin.H{"id": id, "email": d.Email})
+		return
+	}
+	if known && now.Sub(d.ConfirmationSent) < digestConfirmationInterval {
+		ctx.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "the confirmation email was sent recently"})
+		return
+	}
+	if !known {
+		d = Digest{
+			Email:            address.Address,
+			Subscription:     subscription,
+			ConfirmToken:     randomHex(16),
+			UnsubscribeToken: randomHex(16),
+			Created:          now,
+		}
+	}
+	d.ConfirmationSent = now
+
+	if err := a.store.Save("subscriptions", subscription, s); err != nil {
+		sentry.CaptureException(err)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if err := a.store.Save("digests", id, d); err != nil {
+		sentry.CaptureException(err)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	unsubscribeURL := a.mailer.unsubscribeURL(id, d)
+	if err := a.mailer.Send(d.Email, "Confirm your weekly TUM digest", buildConfirmation(a.mailer.confirmURL(id, d), unsubscribeURL), unsubscribeURL); err != nil {
+		sentry.CaptureException(err)
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{"error": "can't send the confirmation email"})
+		return
+	}
+	ctx.JSON(http.StatusAccepted, gin.H{"id": id, "email": d.Email})
+}
+
+// loadDigestWithToken loads the digest of the id parameter if the token parameter matches the token of the digest
+func (a *App) loadDigestWithToken(ctx *gin.Context, token func(d Digest) string) (string, Digest, bool) {
+	id := ctx.Query("id")
+	var d Digest
+	known, err := a.store.Load("digests", id, &d)
+	if err != nil || !known || token(d) == "" || subtle.ConstantTimeCompare([]byte(ctx.Query("token")), []byte(token(d))) != 1 {
+		ctx.String(http.StatusNotFound, "This digest does not exist (anymore).")
+		return "", d, false
+	}
+	return id, d, true
+}
+
+// handleConfirmDigest activates a digest via the link in the confirmation email.
+func (a *App) handleConfirmDigest(ctx *gin.Context) {
+	if a.store == nil {
+		ctx.AbortWithStatus(http.StatusNotImplemented)
+		return
+	}
+	id, d, ok := a.loadDigestWithToken(ctx, func(d Digest) string { return d.ConfirmToken })
+	if !ok {
+		return
+	}
+	d.Confirmed = true
+	d.ConfirmToken = ""
+	if err := a.store.Save("digests", id, d); err != nil {
+		sentry.CaptureException(err)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	ctx.String(http.StatusOK, "You will receive the weekly digest on Sunday evenings.")
+}
+
+// handleUnsubscribeDigest deletes a digest via the link in its emails. The subscription of the feed is kept,
+// it may also be used to find common free slots.
+func (a *App) handleUnsubscribeDigest(ctx *gin.Context) {
+	if a.store == nil {
+		ctx.AbortWithStatus(http.StatusNotImplemented)
+		return
+	}
+	id, _, ok := a.loadDigestWithToken(ctx, func(d Digest) string { return d.UnsubscribeToken })
+	if !ok {
+		return
+	}
+	if err := a.store.Delete("digests", id); err != nil {
+		sentry.CaptureException(err)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	ctx.String(http.StatusOK, "You will not receive the weekly digest anymore.")
+}
@@ -0,0 +1,250 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// smtpStandIn is a minimal local SMTP server that records the messages it receives
+type smtpStandIn struct {
+	listener net.Listener
+	messages chan string
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpStandIn{listener: listener, messages: make(chan string, 10)}
+	go s.serve()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+func (s *smtpStandIn) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpStandIn) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch command := strings.ToUpper(strings.TrimSpace(line)); {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case command == "DATA":
+			reply("354 go ahead")
+			var message strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				message.WriteString(line)
+			}
+			s.messages <- message.String()
+			reply("250 ok")
+		case command == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func TestDigestDue(t *testing.T) {
+	sunday := time.Date(2023, time.January, 8, 19, 0, 0, 0, tumLocation)
+	confirmed := Digest{Confirmed: true}
+	if !digestDue(confirmed, sunday) {
+		t.Error("New digest should be due on sunday evening")
+	}
+	if digestDue(confirmed, sunday.Add(-2*time.Hour)) || digestDue(confirmed, sunday.AddDate(0, 0, 1)) {
+		t.Error("Digest should only be due on sunday evening")
+	}
+	if digestDue(Digest{Confirmed: true, LastSent: sunday.Add(-time.Hour)}, sunday) {
+		t.Error("Digest should not be sent twice on the same evening")
+	}
+	if digestDue(Digest{}, sunday) {
+		t.Error("Digest should not be sent before it was confirmed")
+	}
+}
+
+func TestSendDueDigests(t *testing.T) {
+	calendar, err := os.ReadFile("testdata/location.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tumOnline := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write(calendar) }))
+	defer tumOnline.Close()
+	smtpServer := newSMTPStandIn(t)
+
+	app := newWebhookTestApp(t)
+	app.mailer = &Mailer{addr: smtpServer.listener.Addr().String(), from: "calendar@tum.app", publicURL: "https://cal.tum.app"}
+	if err := app.store.Save("subscriptions", "feed", Subscription{CalendarURL: tumOnline.URL}); err != nil {
+		t.Fatal(err)
+	}
+	digest := Digest{Email: "student@tum.de", Subscription: "feed", Confirmed: true, UnsubscribeToken: "token"}
+	if err := app.store.Save("digests", "digest", digest); err != nil {
+		t.Fatal(err)
+	}
+
+	sunday := time.Date(2023, time.January, 8, 19, 0, 0, 0, tumLocation)
+	app.sendDueDigests(sunday)
+	var message string
+	select {
+	case message = <-smtpServer.messages:
+	case <-time.After(time.Second):
+		t.Fatal("No digest was sent")
+	}
+	for _, expected := range []string{"To: student@tum.de", "Fri 13.01.", "List-Unsubscribe: <https://cal.tum.app/digest/unsubscribe?id=digest&token=token>"} {
+		if !strings.Contains(message, expected) {
+			t.Errorf("Digest should contain %q but is:\n%s", expected, message)
+		}
+	}
+
+	if _, err := app.store.Load("digests", "digest", &digest); err != nil || !digest.LastSent.Equal(sunday) {
+		t.Errorf("Digest should remember when it was sent but has %v (err %v)", digest.LastSent, err)
+	}
+	app.sendDueDigests(sunday.Add(time.Hour))
+	select {
+	case <-smtpServer.messages:
+		t.Error("Digest should only be sent once")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestDigestConfirmation(t *testing.T) {
+	calendar, err := os.ReadFile("testdata/groups.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tumOnline := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("pToken") != "SECRET" {
+			http.Error(w, "invalid token", http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write(calendar)
+	}))
+	defer tumOnline.Close()
+	export := tumOnlineExport
+	tumOnlineExport = tumOnline.URL
+	t.Cleanup(func() { tumOnlineExport = export })
+
+	smtpServer := newSMTPStandIn(t)
+	app := newWebhookTestApp(t)
+	app.mailer = &Mailer{addr: smtpServer.listener.Addr().String(), from: "calendar@tum.app", publicURL: "https://cal.tum.app"}
+	gin.SetMode(gin.TestMode)
+	app.engine = gin.New()
+	app.configRoutes()
+	request := func(method string, target string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		app.engine.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	if w := request(http.MethodPost, "/api/digest?pStud=ABCDEF&pToken=WRONG", `{"email": "student@tum.de"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Subscribing with a wrong token should fail but got %d", w.Code)
+	}
+	if ids, _ := app.store.List("digests"); len(ids) != 0 {
+		t.Errorf("Nothing should be stored for a wrong token but got %v", ids)
+	}
+
+	w := request(http.MethodPost, "/api/digest?pStud=ABCDEF&pToken=SECRET", `{"email": "student@tum.de"}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Subscribing failed with %d", w.Code)
+	}
+	var response struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	var message string
+	select {
+	case message = <-smtpServer.messages:
+	case <-time.After(time.Second):
+		t.Fatal("No confirmation was sent")
+	}
+	var digest Digest
+	if _, err := app.store.Load("digests", response.ID, &digest); err != nil || digest.Confirmed {
+		t.Fatalf("Digest should wait for its confirmation but is %v (err %v)", digest, err)
+	}
+	if known, err := app.store.Load("subscriptions", digest.Subscription, &Subscription{}); err != nil || !known {
+		t.Errorf("Digest should refer to the stored subscription of the feed (err %v)", err)
+	}
+	confirmURL := app.mailer.confirmURL(response.ID, digest)
+	if !strings.Contains(message, confirmURL) {
+		t.Errorf("Confirmation should contain %s but is:\n%s", confirmURL, message)
+	}
+
+	if w := request(http.MethodPost, "/api/digest?pStud=ABCDEF&pToken=SECRET", `{"email": "Student@tum.de"}`); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Subscribing again should not send another confirmation right away but got %d", w.Code)
+	}
+	select {
+	case <-smtpServer.messages:
+		t.Error("The confirmation should not be sent again right away")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if w := request(http.MethodGet, "/digest/confirm?id="+response.ID+"&token=wrong", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Confirming with a wrong token should fail but got %d", w.Code)
+	}
+	if w := request(http.MethodGet, strings.TrimPrefix(confirmURL, app.mailer.publicURL), ""); w.Code != http.StatusOK {
+		t.Errorf("Confirming failed with %d", w.Code)
+	}
+	if _, err := app.store.Load("digests", response.ID, &digest); err != nil || !digest.Confirmed {
+		t.Errorf("Digest should be confirmed but is %v (err %v)", digest, err)
+	}
+
+	if w := request(http.MethodPost, "/api/digest?pStud=ABCDEF&pToken=SECRET", `{"email": "student@tum.de"}`); w.Code != http.StatusOK {
+		t.Errorf("Subscribing to a confirmed digest again should keep it but got %d", w.Code)
+	}
+	if ids, _ := app.store.List("digests"); len(ids) != 1 {
+		t.Errorf("Subscribing again should not add another digest but got %v", ids)
+	}
+	if _, err := app.store.Load("digests", response.ID, &digest); err != nil || !digest.Confirmed {
+		t.Errorf("Digest should stay confirmed but is %v (err %v)", digest, err)
+	}
+}
+
+func TestUnconfirmedDigestExpires(t *testing.T) {
+	app := newWebhookTestApp(t)
+	created := time.Date(2023, time.January, 1, 12, 0, 0, 0, tumLocation)
+	if err := app.store.Save("digests", "digest", Digest{Email: "student@tum.de", ConfirmToken: "token", Created: created}); err != nil {
+		t.Fatal(err)
+	}
+	app.sendDueDigests(created.Add(digestConfirmationTimeout / 2))
+	if known, _ := app.store.Load("digests", "digest", &Digest{}); !known {
+		t.Error("Digest should wait for its confirmation")
+	}
+	app.sendDueDigests(created.Add(digestConfirmationTimeout + time.Hour))
+	if known, _ := app.store.Load("digests", "digest", &Digest{}); known {
+		t.Error("Unconfirmed digest should be deleted")
+	}
+}
@@ -0,0 +1,218 @@
+package internal
+
+import (
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	_ "time/tzdata" // the docker image has no timezone database
+
+	ics "github.com/arran4/golang-ical"
+	"github.com/gin-gonic/gin"
+)
+
+// tumLocation is the timezone all TUM events take place in
+var tumLocation = mustLoadLocation("Europe/Berlin")
+
+func mustLoadLocation(name string) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return location
+}
+
+// Event is the structured form of a cleaned event, which cleanEvent otherwise flattens into the description
+type Event struct {
+	UID             string    `json:"uid"`
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	Title           string    `json:"title"`
+	OriginalTitle   string    `json:"originalTitle"`
+	Type            string    `json:"type,omitempty"`
+	Group           string    `json:"group,omitempty"`
+	Exam            bool      `json:"exam,omitempty"`
+	ModuleCodes     []string  `json:"moduleCodes"`
+	Location        string    `json:"location"`
+	Building        string    `json:"building,omitempty"`
+	Campus          string    `json:"campus,omitempty"`
+	RoomIDs         []string  `json:"roomIds"`
+	NavLinks        []string  `json:"navLinks"`
+	Status          string    `json:"status"`
+	Color           string    `json:"color"`
+	AdditionalRooms []string  `json:"additionalRooms"`
+	Description     string    `json:"description,omitempty"` // the original description from TUMonline
+	URL             string    `json:"url,omitempty"`
+	Meeting         string    `json:"meeting,omitempty"` // the online meeting service, e.g. Zoom
+	MeetingURL      string    `json:"meetingUrl,omitempty"`
+	Conflicts       []string  `json:"conflicts,omitempty"` // UIDs of overlapping events
+	overlapping     []*Event  // the overlapping events themselves, for describing them
+	course          string    // the title without a language, which identifies the course in parameters
+}
+
+// matches the type abbreviation after the tag, e.g. VO in "(IN0004) VO, Standardgruppe"
+var reEventType = regexp.MustCompile(`^ ?[\[(][^\])]*[\])] *([A-Z]{2})\b`)
+
+// eventTypes are the TUMonline abbreviations of the event types
+var eventTypes = map[string]string{
+	"VO": "Lecture",
+	"UE": "Exercise",
+	"VI": "Lecture with integrated exercise",
+	"PR": "Practical Course",
+	"SE": "Seminar",
+	"TT": "Tutorial",
+}
+
+// eventType returns the type of event from the (uncleaned) summary, falling back to the default category of the course
+func (a *App) eventType(summary string) string {
+	if results := reEventType.FindStringSubmatch(reTag.FindString(summary)); len(results) == 2 {
+		if eventType, ok := eventTypes[results[1]]; ok {
+			return eventType
+		}
+		return results[1]
+	}
+	if course := a.lookupCourse(summary); course != nil {
+		return course.Category
+	}
+	return ""
+}
+
+// matches the group number, e.g. 01 in "(IN0001) UE, Gruppe 01", but not "Standardgruppe"
+var reGroup = regexp.MustCompile(`(?i)[ ,](?:gruppe|group) +([0-9a-z]+)\b`)
+
+// eventGroup returns the group of an exercise or tutorial from the (uncleaned) summary
+func eventGroup(summary string) string {
+	if results := reGroup.FindStringSubmatch(summary); len(results) == 2 {
+		return normalizeGroup(results[1])
+	}
+	return ""
+}
+
+// normalizeGroup drops leading zeros and the case of groups, so "01" and "1" or "a" and "A" are the same group
+func normalizeGroup(group string) string {
+	if trimmed := strings.TrimLeft(group, "0"); trimmed != "" {
+		group = trimmed
+	} else if group != "" {
+		group = "0"
+	}
+	return strings.ToUpper(group)
+}
+
+// sortGroups sorts groups numerically, as long as they are numbers
+func sortGroups(groups []string) {
+	slices.SortFunc(groups, func(a, b string) int {
+		if len(a) != len(b) {
+			return len(a) - len(b)
+		}
+		return strings.Compare(a, b)
+	})
+}
+
+// parseDate parses dates like 2024-01-09 in our local timezone, but also accepts full RFC 3339 timestamps
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation(time.DateOnly, value, tumLocation)
+}
+
+// parseRange parses the optional from and to parameters, answering with 400 if they are invalid
+func parseRange(ctx *gin.Context) (time.Time, time.Time, bool) {
+	var from, to time.Time
+	var err error
+	if value := ctx.Query("from"); value != "" {
+		if from, err = parseDate(value); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid from date"})
+			return from, to, false
+		}
+	}
+	if value := ctx.Query("to"); value != "" {
+		if to, err = parseDate(value); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid to date"})
+			return from, to, false
+		}
+	}
+	return from, to, true
+}
+
+// filterEvents keeps the events overlapping [from, to) that belong to one of the courses (cleaned title or module code).
+// Zero times and no courses disable the respective filter.
+func filterEvents(events []*Event, from time.Time, to time.Time, courses []string) []*Event {
+	wanted := make(map[string]bool)
+	for _, course := range courses {
+		wanted[course] = true
+	}
+
+	filtered := make([]*Event, 0, len(events))
+	for _, e := range events {
+		if !from.IsZero() && !e.End.After(from) {
+			continue
+		}
+		if !to.IsZero() && !e.Start.Before(to) {
+			continue
+		}
+		if len(wanted) > 0 && !wanted[e.course] && !containsAny(wanted, e.ModuleCodes) {
+			continue
+		}
+		filtered = append(filtered, e)
+	}
+	sort.SliceStable(filtered, func(i, j int) bool { return filtered[i].Start.Before(filtered[j].Start) })
+	return filtered
+}
+
+// removeEvents drops the events matching remove from the cleaned calendar and from the structured events
+func removeEvents(cal *ics.Calendar, events []*Event, remove func(*Event) bool) []*Event {
+	removed := make(map[string]bool)
+	kept := make([]*Event, 0, len(events))
+	for _, e := range events {
+		if remove(e) {
+			removed[e.UID] = true
+		} else {
+			kept = append(kept, e)
+		}
+	}
+	if len(removed) == 0 {
+		return events
+	}
+
+	components := make([]ics.Component, 0, len(cal.Components))
+	for _, component := range cal.Components {
+		if event, ok := component.(*ics.VEvent); ok && removed[event.Id()] {
+			continue
+		}
+		components = append(components, component)
+	}
+	cal.Components = components
+	return kept
+}
+
+func containsAny(set map[string]bool, values []string) bool {
+	for _, value := range values {
+		if set[value] {
+			return true
+		}
+	}
+	return false
+}
+
+// handleGetEvents returns the cleaned events as a flat JSON list.
+// They can be limited to a date range via from and to (e.g. 2024-01-09) and to some courses via course.
+func (a *App) handleGetEvents(ctx *gin.Context) {
+	from, to, ok := parseRange(ctx)
+	if !ok {
+		return
+	}
+
+	calendarURL := getUrl(ctx)
+	if calendarURL == "" {
+		return
+	}
+	_, events, ok := a.requestFeed(ctx, calendarURL)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, filterEvents(events, from, to, ctx.QueryArray("course")))
+}
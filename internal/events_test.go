@@ -0,0 +1,123 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCleanedEvents(t *testing.T) {
+	testData, app := getTestData(t, "location.ics")
+	_, events, err := app.getCleanedEvents([]byte(testData), map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 event after deduplication but got %d", len(events))
+	}
+
+	e := events[0]
+	if e.Title != "ERA" || e.OriginalTitle != "Einführung in die Rechnerarchitektur (IN0004) VO, Standardgruppe" {
+		t.Errorf("Unexpected titles %q and %q", e.Title, e.OriginalTitle)
+	}
+	if e.Type != "Lecture" {
+		t.Errorf("Type should be Lecture but is %s", e.Type)
+	}
+	if strings.Join(e.ModuleCodes, ",") != "IN0004" {
+		t.Errorf("Module codes should be IN0004 but are %v", e.ModuleCodes)
+	}
+	if e.Building != "Boltzmannstr. 15, 85748 Garching b. München" {
+		t.Errorf("Unexpected building %s", e.Building)
+	}
+	if strings.Join(e.RoomIDs, ",") != "5508.02.801" || strings.Join(e.NavLinks, ",") != "https://nav.tum.de/room/5508.02.801" {
+		t.Errorf("Unexpected rooms %v and nav links %v", e.RoomIDs, e.NavLinks)
+	}
+	if strings.Join(e.AdditionalRooms, ",") != "MI HS 1" {
+		t.Errorf("Additional rooms should be MI HS 1 but are %v", e.AdditionalRooms)
+	}
+	if e.Status != "CONFIRMED" {
+		t.Errorf("Status should be CONFIRMED but is %s", e.Status)
+	}
+	// 12:00 UTC is 13:00 in Munich during winter
+	if e.Start.Format(time.RFC3339) != "2023-01-13T13:00:00+01:00" {
+		t.Errorf("Start should be in local time but is %s", e.Start.Format(time.RFC3339))
+	}
+}
+
+func TestFilterEvents(t *testing.T) {
+	testData, app := getTestData(t, "timeadjustment.ics")
+	_, events, err := app.getCleanedEvents([]byte(testData), map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all := filterEvents(events, time.Time{}, time.Time{}, nil)
+	if len(all) != len(events) {
+		t.Fatalf("Without filters all %d events should be kept but got %d", len(events), len(all))
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i].Start.Before(all[i-1].Start) {
+			t.Error("Events should be sorted by start")
+		}
+	}
+
+	from, _ := parseDate("2024-01-01")
+	to, _ := parseDate("2024-02-01")
+	inJanuary := filterEvents(events, from, to, nil)
+	if len(inJanuary) == 0 || len(inJanuary) == len(events) {
+		t.Errorf("Date range should keep only some of the events but kept %d of %d", len(inJanuary), len(events))
+	}
+	for _, e := range inJanuary {
+		if e.Start.Before(from) || !e.Start.Before(to) {
+			t.Errorf("Event at %s is outside of the date range", e.Start)
+		}
+	}
+
+	if byModule := filterEvents(events, time.Time{}, time.Time{}, []string{"IN2106"}); len(byModule) != len(events) {
+		t.Errorf("All events belong to IN2106, but only %d are kept", len(byModule))
+	}
+	if unknown := filterEvents(events, time.Time{}, time.Time{}, []string{"ERA"}); len(unknown) != 0 {
+		t.Errorf("No event belongs to ERA, but %d are kept", len(unknown))
+	}
+}
+
+func TestEventType(t *testing.T) {
+	app, err := newApp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		"Einführung in die Rechnerarchitektur (IN0004) VO, Standardgruppe": "Lecture",
+		"Analysis für Informatik [MA0902] UE, Gruppe 01":                   "Exercise",
+		"Open Source Lab (IN0012, IN2106, IN4308) PR, Standardgruppe":      "Practical Course",
+		"Some Course (IN9999) XY":                                          "XY",
+		"Einführung in die Theoretische Informatik":                        "Lecture", // from courses.json
+		"Kurs zum/zur Fachsanitäter*in":                                    "",
+	}
+	for summary, expected := range tests {
+		if eventType := app.eventType(summary); eventType != expected {
+			t.Errorf("Type of %q should be %q but is %q", summary, expected, eventType)
+		}
+	}
+}
+
+func TestEventGroup(t *testing.T) {
+	tests := map[string]string{
+		"Analysis für Informatik [MA0902] UE, Gruppe 01":                   "1",
+		"Entwerfen und Konstruieren (AR20001) UE, Gruppe 10":               "10",
+		"Grundlagen (IN0001) TT, Group b":                                  "B",
+		"Einführung in die Rechnerarchitektur (IN0004) VO, Standardgruppe": "",
+		"Gruppendynamik (SOM0001) SE":                                      "",
+	}
+	for summary, expected := range tests {
+		if group := eventGroup(summary); group != expected {
+			t.Errorf("Group of %q should be %q but is %q", summary, expected, group)
+		}
+	}
+
+	groups := []string{"10", "2", "B", "1"}
+	sortGroups(groups)
+	if strings.Join(groups, ",") != "1,2,B,10" {
+		t.Errorf("Groups should be sorted numerically but are %v", groups)
+	}
+}
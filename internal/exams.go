@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"net/http"
+	"regexp"
+	"time"
+
+	ics "github.com/arran4/golang-ical"
+	"github.com/gin-gonic/gin"
+)
+
+// examReminder is the trigger of the alarm of exams, early enough to still prepare
+const examReminder = "-P1D"
+
+// matches exams like "Klausur", "Wiederholungsklausur", "Prüfung" or "Exam" as whole words, but not course names
+// like "Werkstoffprüfung". \b would treat umlauts as word boundaries, so the boundaries are spelled out.
+var reExam = regexp.MustCompile(`(?i)(?:^|[^\pL])(?:(?:wiederholungs)?(?:klausur|prüfung)|exam(?:ination)?s?)(?:[^\pL]|$)`)
+
+// matches events about exams which are no exams themselves, e.g. "Exam Review" or "Prüfung Anmeldung"
+var reNoExam = regexp.MustCompile(`(?i)einsicht|vorbereitung|preparation|review|anmeldung|registration`)
+
+// isExam reports whether the (uncleaned) summary describes an exam. TUMonline puts the type of an event after the
+// module tag, so if there is one, only the words there count, as the course itself may be called "Zerstörungsfreie Prüfung".
+// The description isn't checked, lectures mention their exam there all the time.
+func isExam(summary string) bool {
+	if tag := reTag.FindString(summary); tag != "" {
+		summary = tag
+	}
+	return reExam.MatchString(summary) && !reNoExam.MatchString(summary)
+}
+
+// highlightExams prefixes the summary of exams, as the shortened title alone looks like any other lecture, and reminds of them a day early
+func highlightExams(cal *ics.Calendar, events []*Event, lang *language) {
+	exams := make(map[string]bool)
+	for _, e := range events {
+		if e.Exam {
+			exams[e.UID] = true
+		}
+	}
+	for _, event := range cal.Events() {
+		if !exams[event.Id()] {
+			continue
+		}
+		summary := ""
+		if s := event.GetProperty(ics.ComponentPropertySummary); s != nil {
+			summary = s.Value
+		}
+		summary = lang.label("exam", summary)
+		event.SetSummary(summary)
+
+		alarm := event.AddAlarm()
+		alarm.SetAction(ics.ActionDisplay)
+		alarm.SetTrigger(examReminder)
+		alarm.SetProperty(ics.ComponentPropertyDescription, summary)
+	}
+}
+
+// handleGetExams returns the exams of the current semester as JSON, sorted by start.
+// It takes the same parameters as the feed.
+func (a *App) handleGetExams(ctx *gin.Context) {
+	calendarURL := getUrl(ctx)
+	if calendarURL == "" {
+		return
+	}
+	_, events, ok := a.requestFeed(ctx, calendarURL)
+	if !ok {
+		return
+	}
+
+	from, to := semesterBounds(time.Now())
+	exams := []*Event{}
+	for _, e := range filterEvents(events, from, to, nil) {
+		if e.Exam {
+			exams = append(exams, e)
+		}
+	}
+	ctx.JSON(http.StatusOK, gin.H{"from": from, "to": to, "exams": exams})
+}
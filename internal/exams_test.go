@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsExam(t *testing.T) {
+	tests := map[string]bool{
+		"Einführung in die Rechnerarchitektur (IN0004) Klausur":       true,
+		"Analysis für Informatik [MA0902] Wiederholungsklausur":       true,
+		"Mündliche Prüfung Datenbanksysteme":                          true,
+		"Final Exam Machine Learning":                                 true,
+		"Einführung in die Rechnerarchitektur (IN0004) VO, Standard":  false,
+		"Klausureinsicht Analysis":                                    false,
+		"Prüfungsvorbereitung Lineare Algebra (MA0901) UE, Gruppe 01": false,
+		"Examples and Exercises":                                      false,
+		"Werkstoffprüfung (MW0456) UE, Gruppe 01":                     false,
+		"Zerstörungsfreie Prüfung (MW0123) VO, Standardgruppe":        false,
+		"Werkstoffprüfung":                                            false,
+		"Werkstoffkunde (MW0456) Wiederholungsprüfung":                true,
+		"Prüfung Anmeldung Analysis":                                  false,
+	}
+	for summary, expected := range tests {
+		if exam := isExam(summary); exam != expected {
+			t.Errorf("%q should be an exam: %t, but is %t", summary, expected, exam)
+		}
+	}
+}
+
+func TestHighlightExams(t *testing.T) {
+	testData, app := getTestData(t, "exams.ics")
+	cal, events, err := app.getCleanedEvents([]byte(testData), map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exams := map[string]bool{}
+	for _, e := range events {
+		exams[e.UID] = e.Exam
+	}
+	// 905 is a lecture mentioning its exam in the description
+	expected := map[string]bool{"901@tum.de": false, "902@tum.de": true, "903@tum.de": true, "904@tum.de": false, "905@tum.de": false}
+	for uid, exam := range expected {
+		if exams[uid] != exam {
+			t.Errorf("%s should be an exam: %t", uid, exam)
+		}
+	}
+
+	highlightExams(cal, events, defaultLanguage)
+	for _, event := range cal.Events() {
+		summary := event.GetProperty("SUMMARY").Value
+		if strings.HasPrefix(summary, "Exam: ") != expected[event.Id()] {
+			t.Errorf("Only exams should be prefixed, got %q for %s", summary, event.Id())
+		}
+		if alarms := event.Alarms(); expected[event.Id()] && (len(alarms) != 1 || alarms[0].GetProperty("TRIGGER").Value != examReminder) {
+			t.Errorf("Exam %s should have a reminder the day before", event.Id())
+		}
+	}
+	if count := strings.Count(cal.Serialize(), "CATEGORIES:Exam"); count != 2 {
+		t.Errorf("Both exams should have the Exam category, but %d have it", count)
+	}
+}
@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// exportHeader are the columns of the spreadsheet exports
+var exportHeader = []string{"Start", "End", "Title", "Original Title", "Room", "Building", "Status"}
+
+// eventRows returns one spreadsheet row per event, sorted by start and with times in local time
+func eventRows(events []*Event) [][]string {
+	sorted := append([]*Event(nil), events...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	rows := [][]string{exportHeader}
+	for _, e := range sorted {
+		rows = append(rows, []string{
+			e.Start.In(tumLocation).Format("2006-01-02 15:04"),
+			e.End.In(tumLocation).Format("2006-01-02 15:04"),
+			strings.TrimSpace(e.Title),
+			e.OriginalTitle,
+			e.Location,
+			e.Building,
+			e.Status,
+		})
+	}
+	return rows
+}
+
+// writeCSV writes the events as CSV, e.g. for pasting them into a spreadsheet
+func writeCSV(w io.Writer, events []*Event) error {
+	writer := csv.NewWriter(w)
+	if err := writer.WriteAll(eventRows(events)); err != nil {
+		return fmt.Errorf("can't write csv: %w", err)
+	}
+	return nil
+}
+
+// xlsxFiles are the static parts of a minimal Office Open XML workbook with a single sheet
+var xlsxFiles = map[string]string{
+	"[Content_Types].xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`,
+	"_rels/.rels": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`,
+	"xl/workbook.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Schedule" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+	"xl/_rels/workbook.xml.rels": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`,
+}
+
+// writeXLSX writes the events as an Excel workbook. All cells are inline strings, which every spreadsheet app understands.
+func writeXLSX(w io.Writer, events []*Event) error {
+	var sheet bytes.Buffer
+	sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for i, row := range eventRows(events) {
+		fmt.Fprintf(&sheet, `<row r="%d">`, i+1)
+		for j, cell := range row {
+			fmt.Fprintf(&sheet, `<c r="%s%d" t="inlineStr"><is><t>`, xlsxColumn(j), i+1)
+			if err := xml.EscapeText(&sheet, []byte(cell)); err != nil {
+				return err
+			}
+			sheet.WriteString(`</t></is></c>`)
+		}
+		sheet.WriteString(`</row>`)
+	}
+	sheet.WriteString(`</sheetData></worksheet>`)
+
+	archive := zip.NewWriter(w)
+	// the content types have to come first
+	names := []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels"}
+	for _, name := range names {
+		f, err := archive.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, xlsxFiles[name]); err != nil {
+			return err
+		}
+	}
+	f, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(sheet.Bytes()); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// xlsxColumn returns the spreadsheet name of a zero-based column, e.g. A for 0
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
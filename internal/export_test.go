@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCSVExport(t *testing.T) {
+	testData, app := getTestData(t, "location.ics")
+	_, events, err := app.getCleanedEvents([]byte(testData), map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeCSV(&buf, events); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("Expected a header and 1 event but got %d rows", len(rows))
+	}
+	expected := []string{
+		"2023-01-13 13:00",
+		"2023-01-13 15:00",
+		"ERA",
+		"Einführung in die Rechnerarchitektur (IN0004) VO, Standardgruppe",
+		"MW 1801, Ernst-Schmidt-Hörsaal (5508.02.801)",
+		"Boltzmannstr. 15, 85748 Garching b. München",
+		"CONFIRMED",
+	}
+	if strings.Join(rows[1], "|") != strings.Join(expected, "|") {
+		t.Errorf("Row should be\n%v\nbut is\n%v", expected, rows[1])
+	}
+}
+
+func TestXLSXExport(t *testing.T) {
+	testData, app := getTestData(t, "timeadjustment.ics")
+	_, events, err := app.getCleanedEvents([]byte(testData), map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeXLSX(&buf, events); err != nil {
+		t.Fatal(err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if archive.File[0].Name != "[Content_Types].xml" {
+		t.Errorf("Content types should be the first file but is %s", archive.File[0].Name)
+	}
+	sheet, err := archive.Open("xl/worksheets/sheet1.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(sheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows := strings.Count(string(content), "<row "); rows != len(events)+1 {
+		t.Errorf("Expected %d rows but got %d", len(events)+1, rows)
+	}
+	if !strings.Contains(string(content), "Online: Videokonferenz") {
+		t.Error("Sheet should contain the room of the events")
+	}
+}
+
+func TestXLSXColumn(t *testing.T) {
+	for i, expected := range map[int]string{0: "A", 6: "G", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if column := xlsxColumn(i); column != expected {
+			t.Errorf("Column %d should be %s but is %s", i, expected, column)
+		}
+	}
+}
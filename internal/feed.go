@@ -0,0 +1,167 @@
+package internal
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"text/template"
+	"time"
+
+	ics "github.com/arran4/golang-ical"
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+)
+
+// feedOptions are the parameters changing the events of a feed, shared by all ways to get the feed
+type feedOptions struct {
+	hide    []string
+	filters []eventFilter
+	// from and to are the window of the feed, also included in the filters. Zero times leave it open.
+	from          time.Time
+	to            time.Time
+	lang          *language
+	description   *template.Template
+	colors        map[string]string
+	skipHolidays  bool
+	holidays      bool
+	periods       bool
+	markConflicts bool
+	travel        string
+}
+
+// parseFeedOptions returns the options of the feed parameters, or an error for invalid ones
+func parseFeedOptions(query url.Values, now time.Time) (*feedOptions, error) {
+	filters, err := parseFilters(query, now)
+	if err != nil {
+		return nil, err
+	}
+	from, to, err := parseWindow(query, now)
+	if err != nil {
+		return nil, err
+	}
+	lang, err := parseLanguage(query)
+	if err != nil {
+		return nil, err
+	}
+	description, err := parseDescriptionTemplate(query)
+	if err != nil {
+		return nil, err
+	}
+	return &feedOptions{
+		hide:          query["hide"],
+		filters:       filters,
+		from:          from,
+		to:            to,
+		lang:          lang,
+		description:   description,
+		colors:        parseColorOverrides(query["color"]),
+		skipHolidays:  query.Get("skipHolidays") == "true",
+		holidays:      query.Get("holidays") == "true",
+		periods:       query.Get("periods") == "true",
+		markConflicts: query.Get("markConflicts") == "true",
+		travel:        query.Get("travel"),
+	}, nil
+}
+
+// feedParameters returns the parameters of the feed without the ones identifying the calendar, e.g. to store them
+func feedParameters(query url.Values) url.Values {
+	parameters := url.Values{}
+	for key, values := range query {
+		if key != "pStud" && key != "pPers" && key != "pToken" {
+			parameters[key] = values
+		}
+	}
+	return parameters
+}
+
+func hiddenSet(hide []string) map[string]bool {
+	hidden := make(map[string]bool, len(hide))
+	for _, course := range hide {
+		hidden[course] = true
+	}
+	return hidden
+}
+
+// buildFeed cleans the raw calendar and applies the options, returning the calendar and the structured form of its events
+func (a *App) buildFeed(all []byte, options *feedOptions, now time.Time) (*ics.Calendar, []*Event, error) {
+	a = a.withLanguage(options.lang)
+	cal, events, err := a.getCleanedEvents(all, hiddenSet(options.hide), options.filters...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if options.skipHolidays {
+		events = academic.removeHolidayEvents(cal, events)
+	}
+	if from, to, ok := academicRange(events, options.from, options.to); ok {
+		if options.holidays {
+			academic.addHolidays(cal, from, to, now)
+		}
+		if options.periods {
+			academic.addPeriods(cal, a.lang, from, to, now)
+		}
+	}
+	applyColorOverrides(cal, events, options.colors)
+	applyDescriptionTemplate(cal, events, options.description, a.lang)
+	highlightExams(cal, events, a.lang)
+	if options.markConflicts {
+		markConflicts(cal)
+	}
+	switch options.travel {
+	case "busy":
+		a.addTravelEvents(cal, events, false, now)
+	case "free":
+		a.addTravelEvents(cal, events, true, now)
+	}
+	return cal, events, nil
+}
+
+// feedChanges records the changes of the feed and returns the changes detected so far, newest first.
+// They are detected on the events without filters and in the default language, so all feeds of a calendar hiding
+// the same courses share one history (see feedID), no matter which events the other options drop or how they are named.
+func (a *App) feedChanges(calendarURL string, all []byte, options *feedOptions, events []*Event, now time.Time) ([]Change, error) {
+	if a.store == nil {
+		return nil, nil
+	}
+	if len(options.filters) > 0 || options.skipHolidays || options.lang != nil {
+		var err error
+		if _, events, err = a.getCleanedEvents(all, hiddenSet(options.hide)); err != nil {
+			return nil, err
+		}
+	}
+	return a.trackChanges(feedID(calendarURL, options.hide), events, now)
+}
+
+// fetchFeed fetches the calendar, builds the feed and records its changes. Failures of the change tracking are only
+// reported, the calendar itself is more important than its change history.
+func (a *App) fetchFeed(calendarURL string, options *feedOptions, now time.Time) (*ics.Calendar, []*Event, []Change, error) {
+	all, err := fetchCalendar(calendarURL)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cal, events, err := a.buildFeed(all, options, now)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	changes, err := a.feedChanges(calendarURL, all, options, events, now)
+	if err != nil {
+		log.Printf("can't track changes: %v", err)
+		sentry.CaptureException(err)
+	}
+	return cal, events, changes, nil
+}
+
+// requestFeed builds the feed of the calendar with the parameters of the request, or aborts the request on errors
+func (a *App) requestFeed(ctx *gin.Context, calendarURL string) (*ics.Calendar, []*Event, bool) {
+	options, err := parseFeedOptions(ctx.Request.URL.Query(), time.Now())
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, nil, false
+	}
+	cal, events, _, err := a.fetchFeed(calendarURL, options, time.Now())
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, err)
+		return nil, nil, false
+	}
+	return cal, events, true
+}
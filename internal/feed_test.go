@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	ics "github.com/arran4/golang-ical"
+)
+
+func TestBuildFeed(t *testing.T) {
+	testData, app := getTestData(t, "coursefiltering.ics")
+	now := time.Date(2024, time.January, 10, 12, 0, 0, 0, tumLocation)
+	build := func(query string) (*ics.Calendar, []*Event) {
+		options, err := parseFeedOptions(testQuery(query), now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cal, events, err := app.buildFeed([]byte(testData), options, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cal, events
+	}
+
+	cal, _ := build("markConflicts=true&description=compact")
+	marked := 0
+	for _, event := range cal.Events() {
+		if strings.HasPrefix(event.GetProperty(ics.ComponentPropertySummary).Value, conflictMarker) {
+			marked++
+		}
+	}
+	if marked == 0 {
+		t.Error("Overlapping events should be marked with markConflicts=true")
+	}
+
+	// the events are in January 2023, so only the holidays of the window are added
+	cal, events := build("holidays=true&from=2023-12-20&to=2024-01-10")
+	if len(events) != 0 {
+		t.Errorf("All events should be outside of the window but got %d", len(events))
+	}
+	var holidays []string
+	for _, event := range cal.Events() {
+		holidays = append(holidays, event.Id())
+	}
+	if !slices.Equal(holidays, []string{"holiday-2023-12-25@cal.tum.app", "holiday-2023-12-26@cal.tum.app", "holiday-2024-01-01@cal.tum.app", "holiday-2024-01-06@cal.tum.app"}) {
+		t.Errorf("Expected the holidays of the window but got %v", holidays)
+	}
+
+	if _, err := parseFeedOptions(testQuery("description=unknown"), now); err == nil {
+		t.Error("Unknown descriptions should be rejected")
+	}
+}
+
+func TestFeedCoursesIgnoreLanguage(t *testing.T) {
+	testData, app := getTestData(t, "groups.ics")
+	now := time.Date(2023, time.January, 10, 12, 0, 0, 0, tumLocation)
+	// the course list names the exercise "Ü ERA", which is "Ex ERA" in English
+	options, err := parseFeedOptions(testQuery("lang=en&color=%C3%9C%20ERA:hotpink&group=%C3%9C%20ERA:1"), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cal, events, err := app.buildFeed([]byte(testData), options, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Errorf("Only the lecture and the chosen group should be kept but got %d events", len(events))
+	}
+	for _, event := range cal.Events() {
+		summary := event.GetProperty(ics.ComponentPropertySummary).Value
+		color := event.GetProperty(ics.ComponentPropertyColor).Value
+		if summary == "Ex ERA" && color != "hotpink" {
+			t.Errorf("The exercise should be hotpink in English, too, but is %s", color)
+		} else if summary != "Ex ERA" && color == "hotpink" {
+			t.Errorf("%s should keep its default color", summary)
+		}
+	}
+}
+
+func TestFeedChangesIgnoreOptions(t *testing.T) {
+	calendar, err := os.ReadFile("testdata/tagstripping.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tumOnline := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write(calendar) }))
+	defer tumOnline.Close()
+	app := newWebhookTestApp(t)
+
+	// all events are upcoming, so hiding some of them would look like removals
+	now := time.Date(2020, time.January, 1, 12, 0, 0, 0, tumLocation)
+	fetch := func(query string) ([]*Event, []Change) {
+		options, err := parseFeedOptions(testQuery(query), now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, events, changes, err := app.fetchFeed(tumOnline.URL, options, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return events, changes
+	}
+	all, _ := fetch("")
+	for _, query := range []string{"hideWeekday=tue&lang=en", "description=compact&skipHolidays=true", ""} {
+		events, changes := fetch(query)
+		if len(changes) > 0 {
+			t.Errorf("Feeds of the same calendar should share one history, but %s reported %v", query, changes)
+		}
+		if query == "hideWeekday=tue&lang=en" && len(events) >= len(all) {
+			t.Errorf("The feed should drop the events on tuesdays but has %d of %d", len(events), len(all))
+		}
+	}
+}
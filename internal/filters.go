@@ -0,0 +1,187 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// eventFilter decides during the second pass of getCleanedEvents whether a cleaned event is kept
+type eventFilter func(e *Event) bool
+
+func keepEvent(e *Event, filters []eventFilter) bool {
+	for _, keep := range filters {
+		if !keep(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// windowFilter keeps the events overlapping [from, to), zero times leave the window open
+func windowFilter(from time.Time, to time.Time) eventFilter {
+	return func(e *Event) bool {
+		return (from.IsZero() || e.End.After(from)) && (to.IsZero() || e.Start.Before(to))
+	}
+}
+
+// parseWindow returns the window of events to keep in the feed from the parameters from and to (dates),
+// pastDays (number of days before today) and semester=current. If several are given, the window is their intersection.
+func parseWindow(query url.Values, now time.Time) (time.Time, time.Time, error) {
+	var from, to time.Time
+	narrow := func(start time.Time, end time.Time) {
+		if !start.IsZero() && (from.IsZero() || start.After(from)) {
+			from = start
+		}
+		if !end.IsZero() && (to.IsZero() || end.Before(to)) {
+			to = end
+		}
+	}
+
+	if value := query.Get("from"); value != "" {
+		start, err := parseDate(value)
+		if err != nil {
+			return from, to, errors.New("invalid from date")
+		}
+		narrow(start, time.Time{})
+	}
+	if value := query.Get("to"); value != "" {
+		end, err := parseDate(value)
+		if err != nil {
+			return from, to, errors.New("invalid to date")
+		}
+		narrow(time.Time{}, end)
+	}
+	if value := query.Get("pastDays"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 0 {
+			return from, to, errors.New("invalid pastDays")
+		}
+		narrow(startOfDay(now.In(tumLocation)).AddDate(0, 0, -days), time.Time{})
+	}
+	switch query.Get("semester") {
+	case "":
+	case "current":
+		narrow(semesterBounds(now))
+	default:
+		return from, to, errors.New("only semester=current is supported")
+	}
+	return from, to, nil
+}
+
+// parseFilters returns the filters of the feed parameters
+func parseFilters(query url.Values, now time.Time) ([]eventFilter, error) {
+	from, to, err := parseWindow(query, now)
+	if err != nil {
+		return nil, err
+	}
+	var filters []eventFilter
+	if !from.IsZero() || !to.IsZero() {
+		filters = append(filters, windowFilter(from, to))
+	}
+
+	for _, param := range query["hideWeekday"] {
+		course, value := splitCourseParam(param, strings.LastIndex(param, ":"))
+		weekday, ok := weekdays[strings.ToLower(value)]
+		if !ok {
+			return nil, fmt.Errorf("invalid hideWeekday %s", param)
+		}
+		filters = append(filters, hideWhen(course, func(start time.Time) bool { return start.Weekday() == weekday }))
+	}
+	for _, param := range query["after"] {
+		course, value := splitCourseParam(param, len(param)-len("15:04")-1)
+		after, err := parseClock(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid after %s", param)
+		}
+		filters = append(filters, hideWhen(course, func(start time.Time) bool { return sinceMidnight(start) >= after }))
+	}
+	for _, param := range query["before"] {
+		course, value := splitCourseParam(param, len(param)-len("15:04")-1)
+		before, err := parseClock(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid before %s", param)
+		}
+		filters = append(filters, hideWhen(course, func(start time.Time) bool { return sinceMidnight(start) < before }))
+	}
+
+	groups, err := parseGroups(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) > 0 {
+		filters = append(filters, groupFilter(groups))
+	}
+	return filters, nil
+}
+
+// parseGroups returns the chosen groups per course from parameters like group=ERA:3, which can be repeated
+func parseGroups(query url.Values) (map[string][]string, error) {
+	groups := make(map[string][]string)
+	for _, param := range query["group"] {
+		course, group := splitCourseParam(param, strings.LastIndex(param, ":"))
+		group = normalizeGroup(group)
+		if course == "" || group == "" {
+			return nil, fmt.Errorf("invalid group %s", param)
+		}
+		groups[course] = append(groups[course], group)
+	}
+	return groups, nil
+}
+
+// groupFilter keeps only the chosen groups of the courses (cleaned title or module code), and all events without a group
+func groupFilter(groups map[string][]string) eventFilter {
+	return func(e *Event) bool {
+		if e.Group == "" {
+			return true
+		}
+		for course, chosen := range groups {
+			if isCourse(e, course) && !slices.Contains(chosen, e.Group) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+var weekdays = map[string]time.Weekday{
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+	"sun": time.Sunday, "sunday": time.Sunday,
+}
+
+// splitCourseParam splits parameters like "ERA TÜ:fri" at the colon at index into the course and the value.
+// Without a colon there, the parameter applies to all courses and the course is empty.
+func splitCourseParam(param string, colon int) (string, string) {
+	if colon <= 0 || colon >= len(param) || param[colon] != ':' {
+		return "", param
+	}
+	return strings.TrimSpace(param[:colon]), strings.TrimSpace(param[colon+1:])
+}
+
+func sinceMidnight(t time.Time) time.Duration {
+	return t.Sub(startOfDay(t))
+}
+
+// isCourse reports whether the event belongs to the course, given by its cleaned title or a module code
+func isCourse(e *Event, course string) bool {
+	return e.course == course || slices.Contains(e.ModuleCodes, course)
+}
+
+// hideWhen drops the events of the course (all courses if empty) starting at a matching local time
+func hideWhen(course string, matches func(start time.Time) bool) eventFilter {
+	return func(e *Event) bool {
+		if course != "" && !isCourse(e, course) {
+			return true
+		}
+		return !matches(e.Start.In(tumLocation))
+	}
+}
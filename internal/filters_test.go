@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func testContext(query string) *gin.Context {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest("GET", "/?"+query, nil)
+	return ctx
+}
+
+func testQuery(query string) url.Values {
+	return testContext(query).Request.URL.Query()
+}
+
+func TestParseWindow(t *testing.T) {
+	now := time.Date(2024, time.January, 10, 12, 0, 0, 0, tumLocation)
+	tests := []struct {
+		query string
+		from  string
+		to    string
+	}{
+		{"", "0001-01-01", "0001-01-01"},
+		{"from=2024-01-01&to=2024-02-01", "2024-01-01", "2024-02-01"},
+		{"pastDays=7", "2024-01-03", "0001-01-01"},
+		{"semester=current", "2023-10-01", "2024-04-01"},
+		{"semester=current&pastDays=14&to=2024-03-01", "2023-12-27", "2024-03-01"},
+	}
+	for _, test := range tests {
+		from, to, err := parseWindow(testQuery(test.query), now)
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+			continue
+		}
+		if from.Format(time.DateOnly) != test.from || to.Format(time.DateOnly) != test.to {
+			t.Errorf("%s should keep %s to %s but keeps %s to %s", test.query, test.from, test.to, from.Format(time.DateOnly), to.Format(time.DateOnly))
+		}
+	}
+	for _, query := range []string{"from=yesterday", "pastDays=-1", "semester=next"} {
+		if _, _, err := parseWindow(testQuery(query), now); err == nil {
+			t.Errorf("%s should be rejected", query)
+		}
+	}
+}
+
+func TestWindowFilter(t *testing.T) {
+	testData, app := getTestData(t, "tagstripping.ics")
+	_, all, err := app.getCleanedEvents([]byte(testData), map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	middle := filterEvents(all, time.Time{}, time.Time{}, nil)[len(all)/2]
+
+	cleaned, events, err := app.getCleanedEvents([]byte(testData), map[string]bool{}, windowFilter(middle.Start, time.Time{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) == 0 || len(events) >= len(all) || len(cleaned.Events()) != len(events) {
+		t.Errorf("Expected the later events in the calendar, got %d of %d events and %d components", len(events), len(all), len(cleaned.Events()))
+	}
+	for _, e := range events {
+		if !e.End.After(middle.Start) {
+			t.Errorf("%s ends before the window", e.UID)
+		}
+	}
+}
+
+func TestTimeFilters(t *testing.T) {
+	now := time.Date(2024, time.January, 10, 12, 0, 0, 0, tumLocation)
+	friday := &Event{Title: "ERA TÜ", course: "ERA TÜ", Start: time.Date(2024, time.January, 12, 10, 0, 0, 0, tumLocation)}
+	evening := &Event{Title: "Analysis", course: "Analysis", ModuleCodes: []string{"MA0001"}, Start: time.Date(2024, time.January, 10, 18, 0, 0, 0, tumLocation)}
+	morning := &Event{Title: "Analysis", course: "Analysis", ModuleCodes: []string{"MA0001"}, Start: time.Date(2024, time.January, 11, 8, 30, 0, 0, tumLocation)}
+	tests := []struct {
+		query string
+		kept  []*Event
+	}{
+		{"hideWeekday=fri", []*Event{evening, morning}},
+		{"hideWeekday=Friday", []*Event{evening, morning}},
+		{"hideWeekday=ERA%20TÜ:fri", []*Event{evening, morning}},
+		{"hideWeekday=Analysis:fri", []*Event{friday, evening, morning}},
+		{"after=18:00", []*Event{friday, morning}},
+		{"after=18:01", []*Event{friday, evening, morning}},
+		{"before=09:00", []*Event{friday, evening}},
+		{"before=MA0001:09:00&after=ERA%20TÜ:09:00", []*Event{evening}},
+	}
+	for _, test := range tests {
+		filters, err := parseFilters(testQuery(test.query), now)
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+			continue
+		}
+		var kept []*Event
+		for _, e := range []*Event{friday, evening, morning} {
+			if keepEvent(e, filters) {
+				kept = append(kept, e)
+			}
+		}
+		if len(kept) != len(test.kept) {
+			t.Errorf("%s should keep %d events but keeps %d", test.query, len(test.kept), len(kept))
+			continue
+		}
+		for i := range kept {
+			if kept[i] != test.kept[i] {
+				t.Errorf("%s should keep %s at %s", test.query, test.kept[i].Title, test.kept[i].Start)
+			}
+		}
+	}
+	for _, query := range []string{"hideWeekday=someday", "after=6pm", "before=ERA:25:00"} {
+		if _, err := parseFilters(testQuery(query), now); err == nil {
+			t.Errorf("%s should be rejected", query)
+		}
+	}
+}
+
+func TestGroupFilter(t *testing.T) {
+	now := time.Date(2024, time.January, 10, 12, 0, 0, 0, tumLocation)
+	lecture := &Event{Title: "ERA", course: "ERA", ModuleCodes: []string{"IN0004"}}
+	first := &Event{Title: "ERA", course: "ERA", ModuleCodes: []string{"IN0004"}, Group: "1"}
+	third := &Event{Title: "ERA", course: "ERA", ModuleCodes: []string{"IN0004"}, Group: "3"}
+	other := &Event{Title: "Analysis", course: "Analysis", Group: "1"}
+	tests := []struct {
+		query string
+		kept  []*Event
+	}{
+		{"", []*Event{lecture, first, third, other}},
+		{"group=ERA:03", []*Event{lecture, third, other}},
+		{"group=IN0004:1", []*Event{lecture, first, other}},
+		{"group=ERA:1&group=ERA:3&group=Analysis:2", []*Event{lecture, first, third}},
+	}
+	for _, test := range tests {
+		filters, err := parseFilters(testQuery(test.query), now)
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+			continue
+		}
+		var kept []*Event
+		for _, e := range []*Event{lecture, first, third, other} {
+			if keepEvent(e, filters) {
+				kept = append(kept, e)
+			}
+		}
+		if len(kept) != len(test.kept) {
+			t.Errorf("%s should keep %d events but keeps %d", test.query, len(test.kept), len(kept))
+		}
+	}
+	for _, query := range []string{"group=3", "group=ERA:", "group=:3"} {
+		if _, err := parseFilters(testQuery(query), now); err == nil {
+			t.Errorf("%s should be rejected", query)
+		}
+	}
+}
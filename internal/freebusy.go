@@ -0,0 +1,136 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	ics "github.com/arran4/golang-ical"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultFreeBusyDays is the range of the free/busy information without from and to
+const defaultFreeBusyDays = 28
+
+// timeInterval is a span of time, e.g. in which someone is busy, without any details about why
+type timeInterval struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// busyIntervals merges the non-cancelled events within [from, to) into non-overlapping intervals
+func busyIntervals(events []*Event, from time.Time, to time.Time) []timeInterval {
+	var busy []timeInterval
+	for _, e := range filterEvents(events, from, to, nil) {
+		if e.Status != "CANCELLED" {
+			busy = append(busy, timeInterval{Start: e.Start, End: e.End})
+		}
+	}
+	return mergeIntervals(busy, from, to)
+}
+
+// mergeIntervals sorts the intervals, merges overlapping and adjacent ones and clips them to [from, to)
+func mergeIntervals(intervals []timeInterval, from time.Time, to time.Time) []timeInterval {
+	intervals = slices.Clone(intervals)
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].Start.Before(intervals[j].Start) })
+
+	var merged []timeInterval
+	for _, interval := range intervals {
+		if interval.Start.Before(from) {
+			interval.Start = from
+		}
+		if interval.End.After(to) {
+			interval.End = to
+		}
+		if !interval.End.After(interval.Start) {
+			continue
+		}
+		// the intervals are sorted by start, so only the last one can overlap
+		if last := len(merged) - 1; last >= 0 && !interval.Start.After(merged[last].End) {
+			if interval.End.After(merged[last].End) {
+				merged[last].End = interval.End
+			}
+			continue
+		}
+		merged = append(merged, interval)
+	}
+	return merged
+}
+
+// freeBusyCalendar returns the busy intervals as a VFREEBUSY (RFC 5545, section 3.6.4)
+func freeBusyCalendar(id string, busy []timeInterval, from time.Time, to time.Time, now time.Time) *ics.Calendar {
+	cal := ics.NewCalendar()
+	cal.SetProductId("-//TUM-Dev//Calendar Proxy//EN")
+	cal.SetMethod(ics.MethodPublish)
+
+	freeBusy := cal.AddBusy("freebusy-" + id)
+	freeBusy.SetDtStampTime(now)
+	freeBusy.SetStartAt(from)
+	freeBusy.SetEndAt(to)
+	if len(busy) > 0 {
+		periods := make([]string, 0, len(busy))
+		for _, b := range busy {
+			periods = append(periods, fmt.Sprintf("%s/%s", b.Start.UTC().Format("20060102T150405Z"), b.End.UTC().Format("20060102T150405Z")))
+		}
+		freeBusy.AddProperty(ics.ComponentPropertyFreebusy, strings.Join(periods, ","), &ics.KeyValues{Key: string(ics.ParameterFbtype), Value: []string{string(ics.FreeBusyTimeTypeBusy)}})
+	}
+	return cal
+}
+
+// handleFreeBusy returns when the owner of a stored subscription is busy, without course names or rooms.
+// It takes subscription=<id> instead of the token, which would also open the feed itself, and from and to
+// (default the next four weeks). It answers with a VFREEBUSY or with JSON for format=json.
+func (a *App) handleFreeBusy(ctx *gin.Context) {
+	if a.store == nil {
+		ctx.AbortWithStatusJSON(http.StatusNotImplemented, gin.H{"error": "storage is disabled"})
+		return
+	}
+	id := ctx.Query("subscription")
+	if id == "" {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "subscription is required"})
+		return
+	}
+
+	from, to, ok := parseRange(ctx)
+	if !ok {
+		return
+	}
+	if from.IsZero() {
+		from = startOfDay(time.Now().In(tumLocation))
+	}
+	if to.IsZero() {
+		to = from.AddDate(0, 0, defaultFreeBusyDays)
+	}
+	if !to.After(from) {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "to has to be after from"})
+		return
+	}
+
+	if !reStoreKey.MatchString(id) {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "unknown subscription " + id})
+		return
+	}
+	events, known, err := a.loadSubscriptionEvents(id, time.Now())
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("can't load subscription %s: %v", id, err)})
+		return
+	}
+	if !known {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "unknown subscription " + id})
+		return
+	}
+
+	busy := busyIntervals(events, from, to)
+	if responseFormat(ctx) == "json" {
+		if busy == nil {
+			busy = []timeInterval{}
+		}
+		ctx.JSON(http.StatusOK, gin.H{"from": from, "to": to, "busy": busy})
+		return
+	}
+	cal := freeBusyCalendar(id, busy, from, to, time.Now())
+	ctx.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(cal.Serialize()))
+}
@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBusyIntervals(t *testing.T) {
+	day := time.Date(2024, time.January, 9, 0, 0, 0, 0, tumLocation)
+	events := []*Event{
+		testEvent("ERA", day.Add(8*time.Hour), 120),
+		testEvent("GAD", day.Add(9*time.Hour), 120),
+		testEvent("Theo", day.Add(11*time.Hour), 60),
+		testEvent("DS", day.Add(14*time.Hour), 60),
+		testEvent("Late", day.Add(23*time.Hour), 120),
+	}
+	events[3].Status = "CANCELLED"
+
+	busy := busyIntervals(events, day, day.AddDate(0, 0, 1))
+	if len(busy) != 2 {
+		t.Fatalf("Expected 2 busy intervals but got %v", busy)
+	}
+	if !busy[0].Start.Equal(day.Add(8*time.Hour)) || !busy[0].End.Equal(day.Add(12*time.Hour)) {
+		t.Errorf("Overlapping and adjacent events should be merged into 8-12 but got %v", busy[0])
+	}
+	if !busy[1].End.Equal(day.AddDate(0, 0, 1)) {
+		t.Errorf("Busy intervals should be clipped to the range but got %v", busy[1])
+	}
+}
+
+func TestFreeBusyCalendar(t *testing.T) {
+	day := time.Date(2024, time.January, 9, 0, 0, 0, 0, time.UTC)
+	busy := []timeInterval{{Start: day.Add(8 * time.Hour), End: day.Add(10 * time.Hour)}, {Start: day.Add(12 * time.Hour), End: day.Add(13 * time.Hour)}}
+	serialized := freeBusyCalendar("abc", busy, day, day.AddDate(0, 0, 1), day).Serialize()
+	for _, expected := range []string{"METHOD:PUBLISH", "BEGIN:VFREEBUSY", "UID:freebusy-abc", "FREEBUSY;FBTYPE=BUSY:20240109T080000Z/20240109T100000Z,20240109T120000Z/20240109T130000Z"} {
+		if !strings.Contains(strings.ReplaceAll(strings.ReplaceAll(serialized, "\r\n", "\n"), "\n ", ""), expected) {
+			t.Errorf("Free/busy should contain %s but is:\n%s", expected, serialized)
+		}
+	}
+	if strings.Contains(serialized, "SUMMARY") || strings.Contains(serialized, "LOCATION") {
+		t.Error("Free/busy must not leak event details")
+	}
+}
+
+func TestFreeBusySubscription(t *testing.T) {
+	calendar, err := os.ReadFile("testdata/groups.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tumOnline := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write(calendar) }))
+	defer tumOnline.Close()
+
+	app := newWebhookTestApp(t)
+	gin.SetMode(gin.TestMode)
+	app.engine = gin.New()
+	app.configRoutes()
+	if err := app.store.Save("subscriptions", "alice", Subscription{CalendarURL: tumOnline.URL}); err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		app.engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/freebusy?"+query, nil))
+		return w
+	}
+	if w := request("pStud=ABC&pToken=DEF"); w.Code != http.StatusBadRequest {
+		t.Errorf("Tokens should not be accepted but got %d", w.Code)
+	}
+	if w := request("subscription=unknown"); w.Code != http.StatusNotFound {
+		t.Errorf("Unknown subscriptions should not be found but got %d", w.Code)
+	}
+
+	w := request("subscription=alice&from=2023-01-13&to=2023-01-14&format=json")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Request failed with %d: %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "ERA") || strings.Contains(w.Body.String(), "MI HS 1") {
+		t.Errorf("Free/busy should not expose courses or rooms: %s", w.Body.String())
+	}
+	var response struct {
+		Busy []timeInterval `json:"busy"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	// the exercise groups are from 9 to 11 and from 15 to 17, the lecture from 13 to 15 local time
+	if len(response.Busy) != 2 || response.Busy[0].Start.In(tumLocation).Hour() != 9 || response.Busy[1].End.In(tumLocation).Hour() != 17 {
+		t.Errorf("Expected busy intervals from 9 to 11 and 13 to 17 but got %v", response.Busy)
+	}
+
+	w = request("subscription=alice&from=2023-01-13&to=2023-01-14")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "UID:freebusy-alice") {
+		t.Errorf("Expected a VFREEBUSY of the subscription but got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,102 @@
+package internal
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed languages.json
+var languagesJson string
+
+// language holds the texts the proxy adds to events and the abbreviations of course names in one language
+type language struct {
+	Labels map[string]string `json:"labels"`
+	// Replacements shorten course names before the ones from courses.json, which are German
+	Replacements map[string]string `json:"replacements"`
+}
+
+// languages are the languages selectable with lang=<name>
+var languages = mustParseLanguages(languagesJson)
+
+// defaultLanguage is used without lang, with English labels and the German abbreviations of courses.json
+var defaultLanguage = &language{Labels: languages["en"].Labels}
+
+func mustParseLanguages(raw string) map[string]*language {
+	var l map[string]*language
+	if err := json.Unmarshal([]byte(raw), &l); err != nil {
+		panic(err)
+	}
+	return l
+}
+
+// label returns the text for key, formatted with args if there are any
+func (l *language) label(key string, args ...any) string {
+	label, ok := l.Labels[key]
+	if !ok {
+		label = defaultLanguage.Labels[key]
+	}
+	if len(args) == 0 {
+		return label
+	}
+	return fmt.Sprintf(label, args...)
+}
+
+// forLanguage returns a copy of the app that shortens course names and labels events in the language
+func (a *App) forLanguage(l *language) *App {
+	localized := *a
+	localized.lang = l
+	if a.base == nil {
+		localized.base = a
+	}
+	if len(l.Replacements) == 0 {
+		return &localized
+	}
+	replacements := make([]*Replacement, 0, len(l.Replacements)+len(a.courseReplacements))
+	for key, value := range l.Replacements {
+		replacements = append(replacements, &Replacement{key: key, value: value})
+	}
+	// the language comes first, so it wins over courses.json for the same key after the stable sort
+	sort.Slice(replacements, func(i, j int) bool { return replacements[i].isLessThan(replacements[j]) })
+	replacements = append(replacements, a.courseReplacements...)
+	sort.SliceStable(replacements, func(i, j int) bool { return replacements[i].isLessThan(replacements[j]) })
+	localized.courseReplacements = replacements
+	return &localized
+}
+
+// parseLanguage returns the language chosen with lang=de|en, or nil without it
+func parseLanguage(query url.Values) (*language, error) {
+	name := query.Get("lang")
+	if name == "" {
+		return nil, nil
+	}
+	l, ok := languages[name]
+	if !ok {
+		return nil, errors.New("lang has to be de or en")
+	}
+	return l, nil
+}
+
+// withLanguage returns the app for the language, or the app itself for nil
+func (a *App) withLanguage(l *language) *App {
+	if l == nil {
+		return a
+	}
+	return a.forLanguage(l)
+}
+
+// localized returns the app for the language chosen with lang=de|en, or aborts the request for unknown languages
+func (a *App) localized(ctx *gin.Context) (*App, bool) {
+	l, err := parseLanguage(ctx.Request.URL.Query())
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return a.withLanguage(l), true
+}
@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	ics "github.com/arran4/golang-ical"
+)
+
+func TestLanguageReplacements(t *testing.T) {
+	_, app := getTestData(t, "location.ics")
+	tests := map[string][3]string{
+		// summary: default, de, en
+		"Tutorübungen zu Analysis für Informatik":     {"TÜ zu Analysis", "TÜ zu Analysis", "Tut zu Analysis"},
+		"Zentralübung Lineare Algebra für Informatik": {"ZÜ LinAlg", "ZÜ LinAlg", "CEx LinAlg"},
+		"Vorlesung Datenbanken":                       {"VL DB", "VL DB", "Lec DB"},
+	}
+	for summary, expected := range tests {
+		for i, localized := range []*App{app, app.forLanguage(languages["de"]), app.forLanguage(languages["en"])} {
+			if title := localized.shortenSummary(summary); title != expected[i] {
+				t.Errorf("%q should be shortened to %q but is %q", summary, expected[i], title)
+			}
+		}
+	}
+	if len(app.courseReplacements) >= len(app.forLanguage(languages["en"]).courseReplacements) {
+		t.Errorf("The app itself should not be changed by forLanguage")
+	}
+}
+
+func TestLanguageLabels(t *testing.T) {
+	testData, app := getTestData(t, "location.ics")
+	for name, label := range map[string]string{"de": "Weitere Räume:\n", "en": "Additional rooms:\n"} {
+		cal, err := app.forLanguage(languages[name]).getCleanedCalendar([]byte(testData), map[string]bool{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if description := cal.Events()[0].GetProperty(ics.ComponentPropertyDescription).Value; !strings.HasPrefix(description, label) {
+			t.Errorf("lang=%s should label additional rooms with %q, got %q", name, label, description)
+		}
+	}
+	if label := languages["de"].label("travelDescription", "Garching", "Stammgelände", 45); label != "Von Garching nach Stammgelände, etwa 45 Minuten" {
+		t.Errorf("Labels should be formatted, got %q", label)
+	}
+	if label := (&language{}).label("exam", "ERA"); label != "Exam: ERA" {
+		t.Errorf("Missing labels should fall back to English, got %q", label)
+	}
+
+	for query, ok := range map[string]bool{"": true, "lang=de": true, "lang=en": true, "lang=fr": false} {
+		if _, valid := app.localized(testContext(query)); valid != ok {
+			t.Errorf("%q should be valid: %t", query, ok)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package internal
+
+import (
+	"strconv"
+	"strings"
+
+	ics "github.com/arran4/golang-ical"
+)
+
+// jCal (RFC 7265) represents a calendar as nested JSON arrays:
+// a component is [name, [properties], [components]] and a property is [name, {parameters}, type, values...].
+
+// toJCal converts a calendar to its jCal representation, ready to be marshalled to JSON
+func toJCal(cal *ics.Calendar) []any {
+	properties := make([]ics.IANAProperty, 0, len(cal.CalendarProperties))
+	for _, p := range cal.CalendarProperties {
+		properties = append(properties, ics.IANAProperty{BaseProperty: p.BaseProperty})
+	}
+	return jCalComponent("vcalendar", properties, cal.Components)
+}
+
+func jCalComponent(name string, properties []ics.IANAProperty, components []ics.Component) []any {
+	jProperties := make([]any, 0, len(properties))
+	for _, p := range properties {
+		jProperties = append(jProperties, jCalProperty(p.BaseProperty))
+	}
+	jComponents := make([]any, 0, len(components))
+	for _, component := range components {
+		name, base := componentBase(component)
+		jComponents = append(jComponents, jCalComponent(name, base.Properties, base.Components))
+	}
+	return []any{name, jProperties, jComponents}
+}
+
+func jCalProperty(p ics.BaseProperty) []any {
+	parameters := make(map[string]any)
+	for key, values := range p.ICalParameters {
+		// the value type is part of the property itself in jCal
+		if ics.Parameter(key) == ics.ParameterValue {
+			continue
+		}
+		if len(values) == 1 {
+			parameters[strings.ToLower(key)] = values[0]
+		} else {
+			parameters[strings.ToLower(key)] = values
+		}
+	}
+
+	valueType := jCalValueType(p)
+	property := []any{strings.ToLower(p.IANAToken), parameters, valueType}
+	return append(property, jCalValues(valueType, p.Value)...)
+}
+
+// jCalValueType returns the lowercase jCal type of a property, telling dates and date-times apart by their length
+func jCalValueType(p ics.BaseProperty) string {
+	valueType := p.GetValueType()
+	if valueType == ics.ValueDataTypeDateTime && len(p.Value) == len("20060102") {
+		valueType = ics.ValueDataTypeDate
+	}
+	return strings.ToLower(string(valueType))
+}
+
+func jCalValues(valueType string, value string) []any {
+	var values []any
+	switch valueType {
+	case "text", "unknown":
+		// text may contain unescaped commas, so we can't tell multiple values apart anymore
+		return []any{value}
+	case "float":
+		var floats []any
+		for _, part := range strings.Split(value, ";") {
+			f, err := strconv.ParseFloat(part, 64)
+			if err != nil {
+				return []any{value}
+			}
+			floats = append(floats, f)
+		}
+		if len(floats) == 1 {
+			return floats
+		}
+		// structured values like GEO are a single array of their components
+		return []any{floats}
+	case "recur":
+		recur := make(map[string]any)
+		for _, part := range strings.Split(value, ";") {
+			if key, v, ok := strings.Cut(part, "="); ok {
+				recur[strings.ToLower(key)] = v
+			}
+		}
+		return []any{recur}
+	}
+
+	for _, v := range strings.Split(value, ",") {
+		switch valueType {
+		case "date":
+			values = append(values, toJCalDate(v))
+		case "date-time":
+			values = append(values, toJCalDateTime(v))
+		case "period":
+			start, end, _ := strings.Cut(v, "/")
+			values = append(values, []any{toJCalDateTime(start), toJCalDateTime(end)})
+		case "utc-offset":
+			if len(v) == len("+0100") {
+				v = v[:3] + ":" + v[3:]
+			}
+			values = append(values, v)
+		case "integer":
+			if i, err := strconv.Atoi(v); err == nil {
+				values = append(values, i)
+			} else {
+				values = append(values, v)
+			}
+		case "boolean":
+			values = append(values, strings.EqualFold(v, "TRUE"))
+		default:
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// toJCalDate converts 20060102 to 2006-01-02
+func toJCalDate(v string) string {
+	if len(v) != len("20060102") {
+		return v
+	}
+	return v[0:4] + "-" + v[4:6] + "-" + v[6:8]
+}
+
+// toJCalDateTime converts 20060102T150405Z to 2006-01-02T15:04:05Z and leaves anything else (e.g. durations) untouched
+func toJCalDateTime(v string) string {
+	if len(v) < len("20060102T150405") || v[8] != 'T' {
+		return v
+	}
+	return toJCalDate(v[:8]) + "T" + v[9:11] + ":" + v[11:13] + ":" + v[13:15] + v[15:]
+}
+
+// componentBase returns the lowercase name and the shared base of a component
+func componentBase(component ics.Component) (string, *ics.ComponentBase) {
+	switch c := component.(type) {
+	case *ics.VEvent:
+		return "vevent", &c.ComponentBase
+	case *ics.VTodo:
+		return "vtodo", &c.ComponentBase
+	case *ics.VJournal:
+		return "vjournal", &c.ComponentBase
+	case *ics.VBusy:
+		return "vfreebusy", &c.ComponentBase
+	case *ics.VTimezone:
+		return "vtimezone", &c.ComponentBase
+	case *ics.VAlarm:
+		return "valarm", &c.ComponentBase
+	case *ics.Standard:
+		return "standard", &c.ComponentBase
+	case *ics.Daylight:
+		return "daylight", &c.ComponentBase
+	case *ics.GeneralComponent:
+		return strings.ToLower(c.Token), &c.ComponentBase
+	}
+	return "", &ics.ComponentBase{}
+}
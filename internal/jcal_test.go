@@ -0,0 +1,279 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	ics "github.com/arran4/golang-ical"
+)
+
+func TestJCalRoundTrip(t *testing.T) {
+	files, err := os.ReadDir("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		testData, app := getTestData(t, file.Name())
+		calendar, err := app.getCleanedCalendar([]byte(testData), map[string]bool{})
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		jCal, err := json.Marshal(toJCal(calendar))
+		if err != nil {
+			t.Errorf("%s: can't marshal jCal: %v", file.Name(), err)
+			continue
+		}
+		parsed, err := parseJCal(jCal)
+		if err != nil {
+			t.Errorf("%s: can't parse jCal: %v", file.Name(), err)
+			continue
+		}
+		if expected, actual := calendar.Serialize(), parsed.Serialize(); expected != actual {
+			t.Errorf("%s: calendar should survive the round trip\n\n%s\n\nbut is\n\n%s", file.Name(), expected, actual)
+		}
+	}
+}
+
+func TestJCalValues(t *testing.T) {
+	testData, app := getTestData(t, "location.ics")
+	calendar, err := app.getCleanedCalendar([]byte(testData), map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jCal := toJCal(calendar)
+	if jCal[0] != "vcalendar" {
+		t.Fatalf("Root component should be vcalendar but is %v", jCal[0])
+	}
+
+	event := jCal[2].([]any)[0].([]any)
+	if event[0] != "vevent" {
+		t.Fatalf("Component should be a vevent but is %v", event[0])
+	}
+	found := 0
+	for _, p := range event[1].([]any) {
+		property := p.([]any)
+		switch property[0] {
+		case "dtstart":
+			found++
+			if property[2] != "date-time" || property[3] != "2023-01-13T12:00:00Z" {
+				t.Errorf("dtstart should be a jCal date-time but is %v", property)
+			}
+		case "location":
+			found++
+			if property[2] != "text" || property[3] != "Boltzmannstr. 15, 85748 Garching b. München" {
+				t.Errorf("location should be the cleaned text but is %v", property)
+			}
+		}
+	}
+	if found != 2 {
+		t.Errorf("Expected dtstart and location properties but found %d of them", found)
+	}
+}
+
+func TestParseJCalDate(t *testing.T) {
+	raw := `["vcalendar", [["version", {}, "text", "2.0"]], [["vevent", [["dtstart", {}, "date", "2024-01-09"], ["geo", {}, "float", [48.26, 11.67]]], []]]]`
+	calendar, err := parseJCal([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := calendar.Events()[0]
+	start, err := event.GetAllDayStartAt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start.Format("2006-01-02") != "2024-01-09" {
+		t.Errorf("Start should be 2024-01-09 but is %s", start)
+	}
+	if geo := event.GetProperty("GEO").Value; geo != "48.26;11.67" {
+		t.Errorf("Geo should be 48.26;11.67 but is %s", geo)
+	}
+	if _, err := parseJCal([]byte(`["vevent", [], []]`)); err == nil {
+		t.Error("Documents that are not a vcalendar should be rejected")
+	}
+}
+
+// fromJCalDateTime reverses toJCalDate and toJCalDateTime
+func fromJCalDateTime(v string) string {
+	return strings.NewReplacer("-", "", ":", "").Replace(v)
+}
+
+// newComponent creates an empty component from its jCal name
+func newComponent(name string) (ics.Component, *ics.ComponentBase) {
+	switch name {
+	case "vevent":
+		c := &ics.VEvent{}
+		return c, &c.ComponentBase
+	case "vtodo":
+		c := &ics.VTodo{}
+		return c, &c.ComponentBase
+	case "vjournal":
+		c := &ics.VJournal{}
+		return c, &c.ComponentBase
+	case "vfreebusy":
+		c := &ics.VBusy{}
+		return c, &c.ComponentBase
+	case "vtimezone":
+		c := &ics.VTimezone{}
+		return c, &c.ComponentBase
+	case "valarm":
+		c := &ics.VAlarm{}
+		return c, &c.ComponentBase
+	case "standard":
+		c := &ics.Standard{}
+		return c, &c.ComponentBase
+	case "daylight":
+		c := &ics.Daylight{}
+		return c, &c.ComponentBase
+	}
+	c := &ics.GeneralComponent{Token: strings.ToUpper(name)}
+	return c, &c.ComponentBase
+}
+
+// parseJCal parses a jCal document into a calendar
+func parseJCal(data []byte) (*ics.Calendar, error) {
+	var root []json.RawMessage
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	var name string
+	if len(root) != 3 || json.Unmarshal(root[0], &name) != nil || name != "vcalendar" {
+		return nil, errors.New("jcal: document is not a vcalendar")
+	}
+
+	properties, components, err := parseJCalComponent(root)
+	if err != nil {
+		return nil, err
+	}
+	cal := &ics.Calendar{Components: components}
+	for _, p := range properties {
+		cal.CalendarProperties = append(cal.CalendarProperties, ics.CalendarProperty{BaseProperty: p.BaseProperty})
+	}
+	return cal, nil
+}
+
+func parseJCalComponent(raw []json.RawMessage) ([]ics.IANAProperty, []ics.Component, error) {
+	var jProperties [][]json.RawMessage
+	var jComponents [][]json.RawMessage
+	if err := json.Unmarshal(raw[1], &jProperties); err != nil {
+		return nil, nil, fmt.Errorf("jcal: invalid properties: %w", err)
+	}
+	if err := json.Unmarshal(raw[2], &jComponents); err != nil {
+		return nil, nil, fmt.Errorf("jcal: invalid components: %w", err)
+	}
+
+	var properties []ics.IANAProperty
+	for _, jProperty := range jProperties {
+		p, err := parseJCalProperty(jProperty)
+		if err != nil {
+			return nil, nil, err
+		}
+		properties = append(properties, ics.IANAProperty{BaseProperty: p})
+	}
+
+	var components []ics.Component
+	for _, jComponent := range jComponents {
+		var name string
+		if len(jComponent) != 3 || json.Unmarshal(jComponent[0], &name) != nil {
+			return nil, nil, errors.New("jcal: invalid component")
+		}
+		component, base := newComponent(name)
+		var err error
+		if base.Properties, base.Components, err = parseJCalComponent(jComponent); err != nil {
+			return nil, nil, err
+		}
+		components = append(components, component)
+	}
+	return properties, components, nil
+}
+
+func parseJCalProperty(raw []json.RawMessage) (ics.BaseProperty, error) {
+	var name, valueType string
+	var parameters map[string]any
+	if len(raw) < 4 || json.Unmarshal(raw[0], &name) != nil || json.Unmarshal(raw[1], &parameters) != nil || json.Unmarshal(raw[2], &valueType) != nil {
+		return ics.BaseProperty{}, errors.New("jcal: invalid property")
+	}
+
+	p := ics.BaseProperty{IANAToken: strings.ToUpper(name), ICalParameters: map[string][]string{}}
+	for key, value := range parameters {
+		switch v := value.(type) {
+		case []any:
+			for _, item := range v {
+				p.ICalParameters[strings.ToUpper(key)] = append(p.ICalParameters[strings.ToUpper(key)], fmt.Sprint(item))
+			}
+		default:
+			p.ICalParameters[strings.ToUpper(key)] = []string{fmt.Sprint(v)}
+		}
+	}
+	// only mention the value type if it differs from the default of the property, e.g. for dates
+	defaultType := ics.BaseProperty{IANAToken: p.IANAToken}
+	if strings.ToLower(string(defaultType.GetValueType())) != valueType {
+		p.ICalParameters[string(ics.ParameterValue)] = []string{strings.ToUpper(valueType)}
+	}
+
+	var values []string
+	for _, rawValue := range raw[3:] {
+		var value any
+		if err := json.Unmarshal(rawValue, &value); err != nil {
+			return ics.BaseProperty{}, fmt.Errorf("jcal: invalid value of %s: %w", name, err)
+		}
+		values = append(values, fromJCalValue(valueType, value))
+	}
+	p.Value = strings.Join(values, ",")
+	return p, nil
+}
+
+func fromJCalValue(valueType string, value any) string {
+	switch v := value.(type) {
+	case string:
+		switch valueType {
+		case "date", "date-time":
+			return fromJCalDateTime(v)
+		case "utc-offset":
+			return strings.ReplaceAll(v, ":", "")
+		}
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strings.ToUpper(strconv.FormatBool(v))
+	case []any:
+		var parts []string
+		for _, part := range v {
+			if valueType == "period" {
+				parts = append(parts, fromJCalValue("date-time", part))
+			} else {
+				parts = append(parts, fromJCalValue(valueType, part))
+			}
+		}
+		if valueType == "period" {
+			return strings.Join(parts, "/")
+		}
+		return strings.Join(parts, ";")
+	case map[string]any:
+		// recurrence rules: FREQ has to come first, the remaining parts are sorted for a stable output
+		var keys []string
+		for key := range v {
+			if key != "freq" {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		if _, ok := v["freq"]; ok {
+			keys = append([]string{"freq"}, keys...)
+		}
+		var parts []string
+		for _, key := range keys {
+			parts = append(parts, strings.ToUpper(key)+"="+fmt.Sprint(v[key]))
+		}
+		return strings.Join(parts, ";")
+	}
+	return fmt.Sprint(value)
+}
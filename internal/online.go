@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"regexp"
+
+	ics "github.com/arran4/golang-ical"
+)
+
+// componentPropertyConference is the RFC 7986 property for joining an online meeting, which clients show as a join button
+const componentPropertyConference = ics.ComponentProperty("CONFERENCE")
+
+// meetingProvider recognizes the links of an online meeting service
+type meetingProvider struct {
+	Name string
+	Link *regexp.Regexp
+}
+
+// meetingProviders are the online meeting services used at TUM, matched in this order
+var meetingProviders = []meetingProvider{
+	{"Zoom", regexp.MustCompile(`https://[a-zA-Z0-9.-]*zoom\.us/[^\s"<>\\]+`)},
+	{"BigBlueButton", regexp.MustCompile(`https://[a-zA-Z0-9.-]*\bbbb\b[a-zA-Z0-9.-]*/[^\s"<>\\]+`)},
+	{"Teams", regexp.MustCompile(`https://teams\.(?:microsoft|live)\.com/[^\s"<>\\]+`)},
+	{"Webex", regexp.MustCompile(`https://[a-zA-Z0-9.-]*webex\.com/[^\s"<>\\]+`)},
+}
+
+// matches locations TUMonline uses for online events, like "Online: Videokonferenz"
+var reOnlineLocation = regexp.MustCompile(`(?i)online|videokonferenz|virtuell|virtual`)
+
+// findMeeting returns the provider and link of the first online meeting in the texts, e.g. the location and description
+func findMeeting(texts ...string) (string, string) {
+	for _, provider := range meetingProviders {
+		for _, text := range texts {
+			if link := provider.Link.FindString(text); link != "" {
+				return provider.Name, link
+			}
+		}
+	}
+	return "", ""
+}
+
+// replacesOnlineLocation reports whether the location of an event with a meeting link is replaced with a label like "Online (Zoom)".
+// Events in a known building keep it, as they only offer to join online.
+func replacesOnlineLocation(e *Event) bool {
+	return e.MeetingURL != "" && e.Building == "" && (e.Location == "" || reOnlineLocation.MatchString(e.Location))
+}
+
+// setConference adds the meeting link as URL and CONFERENCE, so clients can offer to join the meeting
+func setConference(event *ics.VEvent, provider string, link string) {
+	event.SetURL(link)
+	event.AddProperty(componentPropertyConference, link,
+		&ics.KeyValues{Key: string(ics.ParameterValue), Value: []string{"URI"}},
+		&ics.KeyValues{Key: "FEATURE", Value: []string{"AUDIO", "VIDEO"}},
+		&ics.KeyValues{Key: "LABEL", Value: []string{provider}},
+	)
+}
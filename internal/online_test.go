@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFindMeeting(t *testing.T) {
+	tests := map[string][2]string{
+		"Zugang: https://tum-conf.zoom.us/j/61234567890?pwd=abc123 Kenncode: 123":                 {"Zoom", "https://tum-conf.zoom.us/j/61234567890?pwd=abc123"},
+		"Stream: https://bbb.rbg.tum.de/b/abc-def-ghi":                                            {"BigBlueButton", "https://bbb.rbg.tum.de/b/abc-def-ghi"},
+		"https://teams.microsoft.com/l/meetup-join/19%3ameeting_abc%40thread.v2/0":                {"Teams", "https://teams.microsoft.com/l/meetup-join/19%3ameeting_abc%40thread.v2/0"},
+		"Webex: https://tum.webex.com/meet/max.mustermann\\; Raum folgt":                          {"Webex", "https://tum.webex.com/meet/max.mustermann"},
+		"Slides at https://www.moodle.tum.de/course/view.php?id=1 and https://abbbey.example/foo": {"", ""},
+	}
+	for text, expected := range tests {
+		if provider, link := findMeeting(text); provider != expected[0] || link != expected[1] {
+			t.Errorf("%q should be %v but is [%s %s]", text, expected, provider, link)
+		}
+	}
+}
+
+func TestOnlineMeetings(t *testing.T) {
+	testData, app := getTestData(t, "online.ics")
+	cal, events, err := app.getCleanedEvents([]byte(testData), map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d", len(events))
+	}
+
+	online := cal.Events()[0]
+	if location := online.GetProperty("LOCATION").Value; location != "Online (Zoom)" {
+		t.Errorf("Online events should have a clean location, got %q", location)
+	}
+	if url := online.GetProperty("URL").Value; url != events[0].MeetingURL || events[0].Meeting != "Zoom" {
+		t.Errorf("The meeting link should be the URL, got %q", url)
+	}
+	if events[0].URL != "https://campus.tum.de/tumonline/wbLv.wbShowLVDetail?pStpSpNr=950630540" {
+		t.Errorf("The event should still link to TUMonline, got %q", events[0].URL)
+	}
+	if description := online.GetProperty("DESCRIPTION").Value; !strings.Contains(description, "Online: Videokonferenz") || !strings.Contains(description, events[0].URL) {
+		t.Errorf("The description should keep the original location and the link to TUMonline, got %q", description)
+	}
+	if serialized := cal.Serialize(); !strings.Contains(serialized, "CONFERENCE;") || !strings.Contains(serialized, "LABEL=Zoom") {
+		t.Errorf("Online events should have a CONFERENCE property, got %s", serialized)
+	}
+
+	// hybrid events stay in their room, but can be joined online
+	hybrid := cal.Events()[1]
+	if location := hybrid.GetProperty("LOCATION").Value; strings.HasPrefix(location, "Online") {
+		t.Errorf("Hybrid events should keep their room, got %q", location)
+	}
+	if hybrid.GetProperty("CONFERENCE") == nil || events[1].Meeting != "BigBlueButton" {
+		t.Errorf("Hybrid events should have a CONFERENCE property")
+	}
+
+	if cal.Events()[2].GetProperty("CONFERENCE") != nil || events[2].MeetingURL != "" {
+		t.Errorf("Events without a meeting link should have no CONFERENCE property")
+	}
+}
@@ -0,0 +1,284 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A4 landscape in PDF points
+const (
+	pdfWidth  = 842.0
+	pdfHeight = 595.0
+	pdfMargin = 30.0
+)
+
+// timetableSlot is a recurring event of the typical week, e.g. every tuesday from 10:15 to 11:45
+type timetableSlot struct {
+	Weekday int // monday is 0
+	Start   int // minutes since midnight
+	End     int
+	Title   string
+	Rooms   []string
+	Color   string
+	Count   int
+	lane    int
+	lanes   int
+}
+
+// roomLabel shortens a raw TUMonline location like "MW 1801, Ernst-Schmidt-Hörsaal (5508.02.801)" to "MW 1801"
+func roomLabel(location string) string {
+	room, _, _ := strings.Cut(location, ",")
+	return strings.TrimSpace(room)
+}
+
+// typicalWeek merges the events into recurring slots by weekday, time and title.
+// Slots that happen only once (e.g. exams) are left out, unless nothing recurs at all.
+func typicalWeek(events []*Event) []*timetableSlot {
+	slots := make(map[string]*timetableSlot)
+	for _, e := range events {
+		if e.Status == "CANCELLED" {
+			continue
+		}
+		start, end := e.Start.In(tumLocation), e.End.In(tumLocation)
+		slot := &timetableSlot{
+			Weekday: (int(start.Weekday()) + 6) % 7,
+			Start:   start.Hour()*60 + start.Minute(),
+			End:     end.Hour()*60 + end.Minute(),
+			Title:   strings.TrimSpace(e.Title),
+			Color:   e.Color,
+		}
+		if end.YearDay() != start.YearDay() {
+			slot.End = 24 * 60
+		}
+		key := fmt.Sprintf("%d-%d-%d-%s", slot.Weekday, slot.Start, slot.End, slot.Title)
+		if existing, ok := slots[key]; ok {
+			slot = existing
+		} else {
+			slots[key] = slot
+		}
+		slot.Count++
+		label := roomLabel(e.Location)
+		if e.Building != "" {
+			label += " · " + e.Building
+		}
+		if label != "" && !slices.Contains(slot.Rooms, label) {
+			slot.Rooms = append(slot.Rooms, label)
+		}
+	}
+
+	var recurring, all []*timetableSlot
+	for _, slot := range slots {
+		all = append(all, slot)
+		if slot.Count > 1 {
+			recurring = append(recurring, slot)
+		}
+	}
+	if len(recurring) > 0 {
+		all = recurring
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Weekday != all[j].Weekday {
+			return all[i].Weekday < all[j].Weekday
+		}
+		if all[i].Start != all[j].Start {
+			return all[i].Start < all[j].Start
+		}
+		return all[i].Title < all[j].Title
+	})
+	assignLanes(all)
+	return all
+}
+
+// assignLanes places overlapping slots of a day side by side. The slots have to be sorted by weekday and start.
+func assignLanes(slots []*timetableSlot) {
+	for i := 0; i < len(slots); {
+		// a group are slots of the same day that (transitively) overlap each other
+		j, groupEnd := i, slots[i].End
+		var laneEnds []int
+		for ; j < len(slots) && slots[j].Weekday == slots[i].Weekday && slots[j].Start < groupEnd; j++ {
+			groupEnd = max(groupEnd, slots[j].End)
+			lane := 0
+			for lane < len(laneEnds) && laneEnds[lane] > slots[j].Start {
+				lane++
+			}
+			if lane == len(laneEnds) {
+				laneEnds = append(laneEnds, 0)
+			}
+			laneEnds[lane] = slots[j].End
+			slots[j].lane = lane
+		}
+		for k := i; k < j; k++ {
+			slots[k].lanes = len(laneEnds)
+		}
+		i = j
+	}
+}
+
+// pdfText converts text to a PDF string literal in WinAnsiEncoding, the encoding of the standard fonts
+func pdfText(s string) string {
+	special := map[rune]byte{'€': 0x80, '…': 0x85, '„': 0x84, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '·': 0xB7, '–': 0x96, '—': 0x97}
+	var b bytes.Buffer
+	b.WriteByte('(')
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x80:
+			b.WriteRune(r)
+		case special[r] != 0:
+			b.WriteByte(special[r])
+		case r >= 0xA0 && r <= 0xFF:
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte('?')
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// fitText cuts text that would not fit into width at the given font size, using the average width of Helvetica
+func fitText(s string, width float64, size float64) string {
+	maxRunes := int(width / (size * 0.52))
+	runes := []rune(s)
+	if len(runes) <= maxRunes {
+		return s
+	}
+	if maxRunes <= 1 {
+		return ""
+	}
+	return string(runes[:maxRunes-1]) + "…"
+}
+
+// pdfColor returns the RGB values of a CSS3 color name, which are the only colors of events, or gray for anything else
+func pdfColor(color string) string {
+	rgb, ok := cssColors[strings.ToLower(color)]
+	if !ok {
+		rgb = cssColors["gray"]
+	}
+	return fmt.Sprintf("%.3f %.3f %.3f", float64(rgb[0])/255, float64(rgb[1])/255, float64(rgb[2])/255)
+}
+
+// timetableContent draws the typical week as a PDF content stream
+func timetableContent(title string, slots []*timetableSlot) string {
+	firstHour, lastHour, days := 8, 18, 5
+	for _, slot := range slots {
+		firstHour = min(firstHour, slot.Start/60)
+		lastHour = max(lastHour, (slot.End+59)/60)
+		if slot.Weekday >= 5 {
+			days = 7
+		}
+	}
+
+	const timeColumn, header = 40.0, 20.0
+	top := pdfHeight - pdfMargin - 24 // leave room for the title
+	gridHeight := top - header - pdfMargin
+	dayWidth := (pdfWidth - 2*pdfMargin - timeColumn) / float64(days)
+	perMinute := gridHeight / float64((lastHour-firstHour)*60)
+	y := func(minutes int) float64 { return top - header - float64(minutes-firstHour*60)*perMinute }
+
+	var c strings.Builder
+	fmt.Fprintf(&c, "BT /F2 16 Tf %.1f %.1f Td %s Tj ET\n", pdfMargin, pdfHeight-pdfMargin-12, pdfText(title))
+
+	// hour lines and labels
+	c.WriteString("0.85 0.85 0.85 RG 0.5 w\n")
+	for hour := firstHour; hour <= lastHour; hour++ {
+		fmt.Fprintf(&c, "%.1f %.1f m %.1f %.1f l S\n", pdfMargin, y(hour*60), pdfWidth-pdfMargin, y(hour*60))
+		if hour < lastHour {
+			fmt.Fprintf(&c, "BT /F1 8 Tf 0.4 0.4 0.4 rg %.1f %.1f Td %s Tj ET\n", pdfMargin, y(hour*60)-9, pdfText(fmt.Sprintf("%02d:00", hour)))
+		}
+	}
+
+	// day headers
+	weekdays := []string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday", "Sunday"}
+	for day := 0; day < days; day++ {
+		x := pdfMargin + timeColumn + float64(day)*dayWidth
+		fmt.Fprintf(&c, "BT /F2 10 Tf 0 0 0 rg %.1f %.1f Td %s Tj ET\n", x+2, top-14, pdfText(weekdays[day]))
+	}
+
+	// the slots with short name, time and rooms
+	for _, slot := range slots {
+		width := dayWidth / float64(slot.lanes)
+		x := pdfMargin + timeColumn + float64(slot.Weekday)*dayWidth + float64(slot.lane)*width
+		y0, y1 := y(slot.End), y(slot.Start)
+		fmt.Fprintf(&c, "%s rg %.1f %.1f %.1f %.1f re f\n", pdfColor(slot.Color), x+1, y0+1, width-2, y1-y0-2)
+
+		lines := []string{slot.Title, fmt.Sprintf("%02d:%02d–%02d:%02d", slot.Start/60, slot.Start%60, slot.End/60, slot.End%60)}
+		lines = append(lines, slot.Rooms...)
+		for i, line := range lines {
+			lineY := y1 - 10 - float64(i)*9
+			if lineY < y0+2 {
+				break
+			}
+			font, size := "/F1", 7.0
+			if i == 0 {
+				font, size = "/F2", 8.0
+			}
+			fmt.Fprintf(&c, "BT %s %.0f Tf 1 1 1 rg %.1f %.1f Td %s Tj ET\n", font, size, x+3, lineY, pdfText(fitText(line, width-6, size)))
+		}
+	}
+	return c.String()
+}
+
+// writePDF writes a single A4 landscape page with the given content stream, using Helvetica as font
+func writePDF(w io.Writer, content string) error {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 4 0 R /F2 5 0 R >> >> /Contents 6 0 R >>", pdfWidth, pdfHeight),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+	}
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// writeTimetablePDF writes the typical week of the semester around now as a printable PDF
+func writeTimetablePDF(w io.Writer, events []*Event, now time.Time) error {
+	start, end := semesterBounds(now)
+	semester := filterEvents(events, start, end, nil)
+	if len(semester) == 0 && len(events) > 0 {
+		// nothing this semester (yet), so fall back to the semester of the latest event
+		latest := events[0]
+		for _, e := range events {
+			if e.Start.After(latest.Start) {
+				latest = e
+			}
+		}
+		start, end = semesterBounds(latest.Start)
+		semester = filterEvents(events, start, end, nil)
+	}
+
+	title := fmt.Sprintf("Timetable %s", semesterName(start))
+	return writePDF(w, timetableContent(title, typicalWeek(semester)))
+}
+
+// semesterName returns the usual TUM name of the semester starting at start, e.g. "WiSe 2023/24" or "SoSe 2024"
+func semesterName(start time.Time) string {
+	if start.Month() == time.October {
+		return fmt.Sprintf("WiSe %d/%02d", start.Year(), (start.Year()+1)%100)
+	}
+	return fmt.Sprintf("SoSe %d", start.Year())
+}
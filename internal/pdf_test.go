@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testEvent(title string, start time.Time, minutes int) *Event {
+	return &Event{
+		Title:    title,
+		Start:    start,
+		End:      start.Add(time.Duration(minutes) * time.Minute),
+		Location: "MW 1801, Ernst-Schmidt-Hörsaal (5508.02.801)",
+		Status:   "CONFIRMED",
+		Color:    "steelblue",
+	}
+}
+
+func TestTypicalWeek(t *testing.T) {
+	monday := time.Date(2024, time.January, 8, 10, 15, 0, 0, tumLocation)
+	events := []*Event{
+		testEvent("ERA", monday, 90),
+		testEvent("ERA", monday.AddDate(0, 0, 7), 90),
+		testEvent("ERA", monday.AddDate(0, 0, 14), 90),
+		testEvent("GAD", monday.Add(30*time.Minute), 90),
+		testEvent("GAD", monday.AddDate(0, 0, 7).Add(30*time.Minute), 90),
+		testEvent("Exam", monday.AddDate(0, 0, 30), 120),
+	}
+	events[2].Status = "CANCELLED"
+
+	slots := typicalWeek(events)
+	if len(slots) != 2 {
+		t.Fatalf("Expected the 2 recurring slots but got %d", len(slots))
+	}
+	if slots[0].Title != "ERA" || slots[0].Count != 2 || slots[0].Start != 10*60+15 {
+		t.Errorf("Unexpected first slot %+v", slots[0])
+	}
+	if slots[0].lanes != 2 || slots[0].lane == slots[1].lane {
+		t.Errorf("Overlapping slots should be placed side by side, but are in lanes %d and %d of %d", slots[0].lane, slots[1].lane, slots[0].lanes)
+	}
+	if len(slots[0].Rooms) != 1 || slots[0].Rooms[0] != "MW 1801" {
+		t.Errorf("Rooms should be merged to MW 1801 but are %v", slots[0].Rooms)
+	}
+
+	if single := typicalWeek(events[5:]); len(single) != 1 {
+		t.Errorf("If nothing recurs, all slots should be kept but got %d", len(single))
+	}
+}
+
+func TestTimetablePDF(t *testing.T) {
+	monday := time.Date(2024, time.January, 8, 10, 15, 0, 0, tumLocation)
+	events := []*Event{testEvent("Übung (ERA)", monday, 90), testEvent("Übung (ERA)", monday.AddDate(0, 0, 7), 90)}
+
+	var buf bytes.Buffer
+	if err := writeTimetablePDF(&buf, events, monday); err != nil {
+		t.Fatal(err)
+	}
+	pdf := buf.Bytes()
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Fatal("Output is no PDF")
+	}
+	if !bytes.Contains(pdf, []byte("(Timetable WiSe 2023/24)")) {
+		t.Error("PDF should be titled with the semester")
+	}
+	// umlauts are encoded in WinAnsiEncoding and brackets are escaped
+	if !bytes.Contains(pdf, []byte("(\xdcbung \\(ERA\\))")) {
+		t.Error("PDF should contain the encoded title")
+	}
+
+	// every object has to be where the cross-reference table says it is
+	xref := regexp.MustCompile(`(?s)startxref\n(\d+)`).FindSubmatch(pdf)
+	start, _ := strconv.Atoi(string(xref[1]))
+	entries := strings.Split(string(pdf[start:]), "\n")[3:9]
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(entry[:10])
+		if expected := fmt.Sprintf("%d 0 obj", i+1); !bytes.HasPrefix(pdf[offset:], []byte(expected)) {
+			t.Errorf("Object %d is not at offset %d", i+1, offset)
+		}
+	}
+}
+
+func TestColorsHaveRGB(t *testing.T) {
+	app, err := newApp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	colors := append([]string(nil), colorPalette...)
+	for _, course := range app.courses {
+		if course.Color != "" {
+			colors = append(colors, course.Color)
+		}
+	}
+	for _, color := range colors {
+		if _, ok := cssColors[color]; !ok {
+			t.Errorf("Color %s is no CSS3 color name", color)
+		}
+	}
+}
+
+func TestPDFColor(t *testing.T) {
+	tests := map[string]string{
+		"steelblue": "0.275 0.510 0.706",
+		"SteelBlue": "0.275 0.510 0.706",
+		"wheat":     "0.961 0.871 0.702",
+		"foo":       "0.502 0.502 0.502",
+	}
+	for color, expected := range tests {
+		if rgb := pdfColor(color); rgb != expected {
+			t.Errorf("%s should be %s but is %s", color, expected, rgb)
+		}
+	}
+}
@@ -1,4 +1,5 @@
 const hiddenCourses = new Set();
+const chosenGroups = new Map();
 let originalLink = null;
 
 function getAndCheckCalLink() {
@@ -37,6 +38,9 @@ function generateLink() {
     for (const courseName of hiddenCourses) {
           queryParams.append("hide", courseName);
     }
+    for (const [courseName, group] of chosenGroups) {
+        queryParams.append("group", `${courseName}:${group}`);
+    }
 
     adjustedLink.search = queryParams;
     copyToClipboard(adjustedLink.toString());
@@ -69,6 +73,7 @@ function reloadCourses() {
             // add checkboxes for each course in courseAdjustList
             const courseAdjustList = document.getElementById("courseAdjustList");
             courseAdjustList.innerHTML = "";
+            chosenGroups.clear();
 
             for (const [key, course] of Object.entries(courses)) {
                 const li = document.createElement("li");
@@ -85,7 +90,43 @@ function reloadCourses() {
                     setCopyButton("reset");
                 };
                 li.appendChild(input);
-                li.appendChild(document.createTextNode(course.summary));
+                const color = document.createElement("span");
+                color.className = "courseColor";
+                color.style.backgroundColor = course.color;
+                li.appendChild(color);
+                li.appendChild(document.createTextNode(course.title));
+                if (course.metadata && course.metadata.moduleId) {
+                    const moduleId = document.createElement("small");
+                    moduleId.innerText = ` (${course.metadata.moduleId})`;
+                    li.appendChild(moduleId);
+                }
+                if (course.groups && course.groups.length > 0) {
+                    const select = document.createElement("select");
+                    select.className = "courseGroup";
+                    select.appendChild(new Option("all groups", ""));
+                    for (const group of course.groups) {
+                        select.appendChild(new Option(`group ${group}`, group));
+                    }
+                    if (course.chosenGroups && course.chosenGroups.length > 0) {
+                        select.value = course.chosenGroups[0];
+                        chosenGroups.set(key, select.value);
+                    }
+                    select.onchange = () => {
+                        if (select.value === "") {
+                            chosenGroups.delete(key);
+                        } else {
+                            chosenGroups.set(key, select.value);
+                        }
+                        setCopyButton("reset");
+                    };
+                    li.appendChild(select);
+                }
+                if (course.conflicts && course.conflicts.length > 0) {
+                    const conflicts = document.createElement("small");
+                    conflicts.className = "courseConflict";
+                    conflicts.innerText = ` ⚠ overlaps with ${course.conflicts.join(", ")}`;
+                    li.appendChild(conflicts);
+                }
                 courseAdjustList.appendChild(li);
             }
 
@@ -0,0 +1,146 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Store persists JSON documents per subscription in a directory, e.g. data/snapshots/<subscription>.json.
+// It is meant for the small amount of state we need, so there is no database to operate.
+type Store struct {
+	dir string
+	mu  sync.Mutex
+	// locks are held across a load and a save of the same document, see Lock
+	locks map[string]*sync.Mutex
+}
+
+// valid kinds and ids, so they can't escape the data directory
+var reStoreKey = regexp.MustCompile("^[a-z0-9-]+$")
+
+func newStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("can't create data directory: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+func (s *Store) path(kind string, id string) (string, error) {
+	if !reStoreKey.MatchString(kind) || !reStoreKey.MatchString(id) {
+		return "", fmt.Errorf("invalid store key %s/%s", kind, id)
+	}
+	return filepath.Join(s.dir, kind, id+".json"), nil
+}
+
+// Load reads the document of kind for id into v and reports whether it existed
+func (s *Store) Load(kind string, id string, v any) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path, err := s.path(kind, id)
+	if err != nil {
+		return false, err
+	}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(raw, v)
+}
+
+// Save writes v as the document of kind for id. The file is replaced atomically, so readers never see half a document.
+func (s *Store) Save(kind string, id string, v any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path, err := s.path(kind, id)
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Lock holds the documents of kind for id until the returned function is called.
+// Load and Save are atomic on their own, Lock makes a load, an update and a save atomic.
+func (s *Store) Lock(kind string, id string) func() {
+	s.mu.Lock()
+	if s.locks == nil {
+		s.locks = make(map[string]*sync.Mutex)
+	}
+	key := kind + "/" + id
+	l, ok := s.locks[key]
+	if !ok {
+		l = &sync.Mutex{}
+		s.locks[key] = l
+	}
+	s.mu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+// List returns the ids of all documents of kind
+func (s *Store) List(kind string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !reStoreKey.MatchString(kind) {
+		return nil, fmt.Errorf("invalid store kind %s", kind)
+	}
+	entries, err := os.ReadDir(filepath.Join(s.dir, kind))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, entry := range entries {
+		if id, ok := strings.CutSuffix(entry.Name(), ".json"); ok && reStoreKey.MatchString(id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// Delete removes the document of kind for id, if it exists
+func (s *Store) Delete(kind string, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path, err := s.path(kind, id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// subscriptionID identifies a subscription by its TUMonline URL without storing the token itself
+func subscriptionID(calendarURL string) string {
+	sum := sha256.Sum256([]byte(calendarURL))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,39 @@
+package internal
+
+import (
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	store, err := newStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var value []string
+	if known, err := store.Load("snapshots", "abc", &value); err != nil || known {
+		t.Errorf("Missing document should not be known (err %v)", err)
+	}
+	if err := store.Save("snapshots", "abc", []string{"ERA", "GAD"}); err != nil {
+		t.Fatal(err)
+	}
+	if known, err := store.Load("snapshots", "abc", &value); err != nil || !known {
+		t.Errorf("Saved document should be known (err %v)", err)
+	}
+	if len(value) != 2 || value[1] != "GAD" {
+		t.Errorf("Loaded document should match the saved one but is %v", value)
+	}
+	if err := store.Save("snapshots", "../escape", value); err == nil {
+		t.Error("Ids must not be able to escape the data directory")
+	}
+}
+
+func TestSubscriptionID(t *testing.T) {
+	id := subscriptionID("https://campus.tum.de/tumonlinej/ws/termin/ical?pStud=ABC&pToken=SECRET")
+	if !reStoreKey.MatchString(id) {
+		t.Errorf("Subscription id should be usable as store key but is %s", id)
+	}
+	if id == subscriptionID("https://campus.tum.de/tumonlinej/ws/termin/ical?pStud=ABC&pToken=OTHER") {
+		t.Error("Different calendars should have different subscription ids")
+	}
+}
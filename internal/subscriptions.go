@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+)
+
+// Subscription is a stored link to a calendar, so others can refer to it by its id without knowing the token,
+// e.g. to find common free slots of a study group, and the calendar can be fetched in the background for digests.
+type Subscription struct {
+	CalendarURL string `json:"calendarUrl"`
+	// Options are the parameters of the feed besides the calendar, like hide or group
+	Options url.Values `json:"options"`
+	Created time.Time  `json:"created"`
+}
+
+// newSubscription returns the requested feed as subscription with its id, the same feed always gets the same id.
+// It fails for invalid feed parameters.
+func newSubscription(ctx *gin.Context) (string, Subscription, error) {
+	s := Subscription{CalendarURL: getCalendarURL(ctx), Options: feedParameters(ctx.Request.URL.Query()), Created: time.Now()}
+	if _, err := parseFeedOptions(s.Options, s.Created); err != nil {
+		return "", s, err
+	}
+	return subscriptionID(s.CalendarURL + "\n" + s.Options.Encode()), s, nil
+}
+
+// subscriptionFeed fetches the calendar of a subscription, builds its feed and records its changes
+func (a *App) subscriptionFeed(s Subscription, now time.Time) ([]*Event, []Change, error) {
+	options, err := parseFeedOptions(s.Options, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, events, changes, err := a.fetchFeed(s.CalendarURL, options, now)
+	return events, changes, err
+}
+
+// loadSubscriptionEvents fetches the events of the feed of a stored subscription
+func (a *App) loadSubscriptionEvents(id string, now time.Time) ([]*Event, bool, error) {
+	var s Subscription
+	known, err := a.store.Load("subscriptions", id, &s)
+	if err != nil || !known {
+		return nil, known, err
+	}
+	events, _, err := a.subscriptionFeed(s, now)
+	return events, true, err
+}
+
+// handleStoreSubscription stores the link of a feed and returns its id. Storing the same feed again returns the same id.
+func (a *App) handleStoreSubscription(ctx *gin.Context) {
+	if a.store == nil {
+		ctx.AbortWithStatusJSON(http.StatusNotImplemented, gin.H{"error": "storage is disabled"})
+		return
+	}
+	if getCalendarURL(ctx) == "" {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "pStud or pPers and pToken are required"})
+		return
+	}
+
+	id, s, err := newSubscription(ctx)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := a.store.Save("subscriptions", id, s); err != nil {
+		sentry.CaptureException(err)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"id": id})
+}
+
+// handleDeleteSubscription removes the stored link of a feed. It takes the feed parameters, so only the owner can delete it.
+func (a *App) handleDeleteSubscription(ctx *gin.Context) {
+	if a.store == nil {
+		ctx.AbortWithStatusJSON(http.StatusNotImplemented, gin.H{"error": "storage is disabled"})
+		return
+	}
+	if getCalendarURL(ctx) == "" {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "pStud or pPers and pToken are required"})
+		return
+	}
+	id, _, err := newSubscription(ctx)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := a.store.Delete("subscriptions", id); err != nil {
+		sentry.CaptureException(err)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,30 @@
+package internal
+
+import (
+	"time"
+
+	ics "github.com/arran4/golang-ical"
+)
+
+// travelColor is the color of the travel events, which should not stand out like courses
+const travelColor = "gray"
+
+// addTravelEvents inserts an event for the time needed to change campuses between two events.
+// Transparent travel events are only shown, but don't block the time in free/busy lookups of the calendar client.
+func (a *App) addTravelEvents(cal *ics.Calendar, events []*Event, transparent bool, now time.Time) {
+	for _, travel := range a.travelSlots(events) {
+		event := cal.AddEvent("travel-" + travel.Before)
+		event.SetDtStampTime(now)
+		event.SetStartAt(travel.Start)
+		event.SetEndAt(travel.End)
+		event.SetSummary(a.lang.label("travelTo", travel.To))
+		event.SetDescription(a.lang.label("travelDescription", travel.From, travel.To, int(a.travelTime(travel.From, travel.To).Minutes())))
+		event.AddCategory("Travel")
+		event.SetColor(travelColor)
+		if transparent {
+			event.SetTimeTransparency(ics.TransparencyTransparent)
+		} else {
+			event.SetTimeTransparency(ics.TransparencyOpaque)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	ics "github.com/arran4/golang-ical"
+)
+
+func TestEventCampus(t *testing.T) {
+	testData, app := getTestData(t, "location.ics")
+	_, events, err := app.getCleanedEvents([]byte(testData), map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events[0].Campus != "Garching" {
+		t.Errorf("Event in building 5508 should be in Garching but is in %q", events[0].Campus)
+	}
+}
+
+func TestAddTravelEvents(t *testing.T) {
+	_, app := getTestData(t, "location.ics")
+	day := time.Date(2024, time.January, 9, 0, 0, 0, 0, tumLocation)
+	events := []*Event{
+		testEvent("ERA", day.Add(8*time.Hour), 120),
+		testEvent("GAD", day.Add(10*time.Hour+15*time.Minute), 90),
+		testEvent("Theo", day.Add(14*time.Hour), 60),
+		testEvent("DS", day.AddDate(0, 0, 1).Add(8*time.Hour), 60),
+	}
+	for i, campus := range []string{"Garching", "Stammgelände", "Stammgelände", "Garching"} {
+		events[i].UID = events[i].Title
+		events[i].Campus = campus
+	}
+
+	cal := ics.NewCalendar()
+	app.addTravelEvents(cal, events, true, day)
+	travel := cal.Events()
+	if len(travel) != 1 {
+		t.Fatalf("Expected one travel event but got %d", len(travel))
+	}
+	if travel[0].Id() != "travel-GAD" || travel[0].GetProperty(ics.ComponentPropertySummary).Value != "Travel to Stammgelände" {
+		t.Errorf("Unexpected travel event %s", travel[0].Serialize(nil))
+	}
+	if start, _ := travel[0].GetStartAt(); !start.Equal(day.Add(10 * time.Hour)) {
+		t.Errorf("Travel should start when ERA ends, as the break is shorter than the travel time, but starts at %v", start)
+	}
+	if transparency := travel[0].GetProperty(ics.ComponentPropertyTransp); transparency == nil || transparency.Value != "TRANSPARENT" {
+		t.Error("Travel should not block the time if requested")
+	}
+}
@@ -0,0 +1,185 @@
+package internal
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed templates
+var templates embed.FS
+
+var viewTemplate = template.Must(template.ParseFS(templates, "templates/view.html"))
+
+// slotsPerHour is the resolution of the weekly grid, one row per 15 minutes
+const slotsPerHour = 4
+
+type viewEvent struct {
+	*Event
+	Column    int
+	Row       int
+	RowEnd    int
+	Cancelled bool
+}
+
+type viewHour struct {
+	Hour int
+	Row  int
+}
+
+type viewDay struct {
+	Date   time.Time
+	Column int
+	Events []viewEvent
+}
+
+type viewWeek struct {
+	Monday  time.Time
+	Link    string
+	Current bool
+	Days    [7][]*Event
+}
+
+type viewData struct {
+	Monday       time.Time
+	PreviousWeek string
+	NextWeek     string
+	Hours        []viewHour
+	Rows         int
+	Days         []viewDay
+	Semester     []viewWeek
+}
+
+// startOfWeek returns the monday of the week t is in, at midnight in our local timezone
+func startOfWeek(t time.Time) time.Time {
+	t = t.In(tumLocation)
+	weekday := (int(t.Weekday()) + 6) % 7 // monday is 0
+	return time.Date(t.Year(), t.Month(), t.Day()-weekday, 0, 0, 0, 0, tumLocation)
+}
+
+// semesterBounds returns the start and (exclusive) end of the TUM semester t is in, as listed in semesters.json.
+// Past the data file, the summer semester lasts from April to September and the winter semester from October to March.
+func semesterBounds(t time.Time) (time.Time, time.Time) {
+	if s, ok := academic.semesterOn(t); ok {
+		return s.Start.Time, s.End.AddDate(0, 0, 1)
+	}
+	t = t.In(tumLocation)
+	year := t.Year()
+	switch {
+	case t.Month() < time.April:
+		return time.Date(year-1, time.October, 1, 0, 0, 0, 0, tumLocation), time.Date(year, time.April, 1, 0, 0, 0, 0, tumLocation)
+	case t.Month() < time.October:
+		return time.Date(year, time.April, 1, 0, 0, 0, 0, tumLocation), time.Date(year, time.October, 1, 0, 0, 0, 0, tumLocation)
+	default:
+		return time.Date(year, time.October, 1, 0, 0, 0, 0, tumLocation), time.Date(year+1, time.April, 1, 0, 0, 0, 0, tumLocation)
+	}
+}
+
+// weekLink returns the current URL (including the calendar and filter parameters) pointing to another week
+func weekLink(query url.Values, monday time.Time) string {
+	q := url.Values{}
+	for key, values := range query {
+		q[key] = values
+	}
+	q.Set("week", monday.Format(time.DateOnly))
+	return "?" + q.Encode()
+}
+
+// buildView lays out the events of the week around monday as a grid and summarizes the semester around it
+func buildView(events []*Event, monday time.Time, query url.Values) viewData {
+	data := viewData{
+		Monday:       monday,
+		PreviousWeek: weekLink(query, monday.AddDate(0, 0, -7)),
+		NextWeek:     weekLink(query, monday.AddDate(0, 0, 7)),
+	}
+
+	// show at least 8 to 18 o'clock, but extend the grid for early or late events
+	week := filterEvents(events, monday, monday.AddDate(0, 0, 7), nil)
+	firstHour, lastHour := 8, 18
+	for _, e := range week {
+		start, end := e.Start.In(tumLocation), e.End.In(tumLocation)
+		firstHour = min(firstHour, start.Hour())
+		lastHour = max(lastHour, end.Hour()+min(1, end.Minute()))
+	}
+	for hour := firstHour; hour < lastHour; hour++ {
+		data.Hours = append(data.Hours, viewHour{Hour: hour, Row: 2 + (hour-firstHour)*slotsPerHour})
+	}
+	data.Rows = (lastHour - firstHour) * slotsPerHour
+
+	// weekends are only shown if something happens there
+	days := 5
+	for _, e := range week {
+		if weekday := e.Start.In(tumLocation).Weekday(); weekday == time.Saturday || weekday == time.Sunday {
+			days = 7
+		}
+	}
+	for i := 0; i < days; i++ {
+		day := viewDay{Date: monday.AddDate(0, 0, i), Column: i + 2}
+		for _, e := range filterEvents(week, day.Date, day.Date.AddDate(0, 0, 1), nil) {
+			start, end := e.Start.In(tumLocation), e.End.In(tumLocation)
+			day.Events = append(day.Events, viewEvent{
+				Event:     e,
+				Column:    day.Column,
+				Row:       2 + (start.Hour()-firstHour)*slotsPerHour + start.Minute()*slotsPerHour/60,
+				RowEnd:    2 + (end.Hour()-firstHour)*slotsPerHour + end.Minute()*slotsPerHour/60,
+				Cancelled: e.Status == "CANCELLED",
+			})
+		}
+		data.Days = append(data.Days, day)
+	}
+
+	// the semester overview lists all weeks with events
+	semesterStart, semesterEnd := semesterBounds(monday)
+	for w := startOfWeek(semesterStart); w.Before(semesterEnd); w = w.AddDate(0, 0, 7) {
+		weekEvents := filterEvents(events, w, w.AddDate(0, 0, 7), nil)
+		if len(weekEvents) == 0 {
+			continue
+		}
+		overview := viewWeek{Monday: w, Link: weekLink(query, w), Current: w.Equal(monday)}
+		for _, e := range weekEvents {
+			weekday := (int(e.Start.In(tumLocation).Weekday()) + 6) % 7
+			overview.Days[weekday] = append(overview.Days[weekday], e)
+		}
+		data.Semester = append(data.Semester, overview)
+	}
+	return data
+}
+
+// handleView renders the cleaned calendar as a weekly grid and a semester overview in the browser.
+// It takes the same parameters as the feed, plus week=<any date in the week>.
+func (a *App) handleView(ctx *gin.Context) {
+	monday := startOfWeek(time.Now())
+	if value := ctx.Query("week"); value != "" {
+		week, err := parseDate(value)
+		if err != nil {
+			ctx.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		monday = startOfWeek(week)
+	}
+
+	calendarURL := getUrl(ctx)
+	if calendarURL == "" {
+		return
+	}
+	_, events, ok := a.requestFeed(ctx, calendarURL)
+	if !ok {
+		return
+	}
+
+	query := ctx.Request.URL.Query()
+	query.Del("week")
+	var buf bytes.Buffer
+	if err := viewTemplate.Execute(&buf, buildView(events, monday, query)); err != nil {
+		sentry.CaptureException(err)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	ctx.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStartOfWeek(t *testing.T) {
+	sunday := time.Date(2024, time.January, 14, 23, 30, 0, 0, tumLocation)
+	if monday := startOfWeek(sunday); monday.Format(time.DateOnly) != "2024-01-08" {
+		t.Errorf("Week of %s should start on 2024-01-08 but starts on %s", sunday, monday)
+	}
+	start, end := semesterBounds(sunday)
+	if start.Format(time.DateOnly) != "2023-10-01" || end.Format(time.DateOnly) != "2024-04-01" {
+		t.Errorf("Winter semester should last from 2023-10-01 to 2024-04-01 but lasts from %s to %s", start, end)
+	}
+}
+
+func TestBuildView(t *testing.T) {
+	testData, app := getTestData(t, "timeadjustment.ics")
+	_, events, err := app.getCleanedEvents([]byte(testData), map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := url.Values{"pStud": {"ABC"}, "pToken": {"XYZ"}, "hide": {"Some Course"}}
+	monday := startOfWeek(time.Date(2024, time.January, 9, 0, 0, 0, 0, tumLocation))
+	view := buildView(events, monday, query)
+
+	if len(view.Days) != 5 {
+		t.Errorf("Without weekend events only 5 days should be shown but got %d", len(view.Days))
+	}
+	tuesday := view.Days[1]
+	if len(tuesday.Events) != 1 {
+		t.Fatalf("Tuesday should have 1 event but has %d", len(tuesday.Events))
+	}
+	// 18:00 to 20:00 local time with the grid starting at 8:00 and 4 rows per hour
+	if e := tuesday.Events[0]; e.Column != 3 || e.Row != 42 || e.RowEnd != 50 {
+		t.Errorf("Event should be in column 3, rows 42 to 50 but is in column %d, rows %d to %d", e.Column, e.Row, e.RowEnd)
+	}
+	if len(view.Hours) != 12 {
+		t.Errorf("Grid should span 8:00 to 20:00 but has %d hours", len(view.Hours))
+	}
+	if len(view.Semester) != 4 {
+		t.Errorf("Semester overview should have 4 weeks with events but has %d", len(view.Semester))
+	}
+
+	link, err := url.Parse(view.NextWeek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.Query().Get("week") != "2024-01-15" || link.Query().Get("pToken") != "XYZ" || link.Query().Get("hide") != "Some Course" {
+		t.Errorf("Link to the next week should keep the calendar and filter parameters but is %s", view.NextWeek)
+	}
+
+	var buf bytes.Buffer
+	if err := viewTemplate.Execute(&buf, view); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Practical Course: Open Source Lab") {
+		t.Error("Rendered view should contain the event")
+	}
+}
@@ -0,0 +1,271 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+)
+
+// maxWebhooks is the number of webhooks a single feed can register
+const maxWebhooks = 5
+
+// webhookAttempts is how often a delivery is tried before it is given up
+const webhookAttempts = 5
+
+// webhookChanges are the types of changes that are worth a notification
+var webhookChanges = []string{changeAdded, changeMoved, changeRoom, changeCancelled}
+
+// Webhook receives a signed JSON payload whenever the feed it was registered for changes
+type Webhook struct {
+	ID      string    `json:"id"`
+	URL     string    `json:"url"`
+	Secret  string    `json:"secret,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// webhookPayload is the body posted to the webhooks
+type webhookPayload struct {
+	Feed    string   `json:"feed"`
+	Changes []Change `json:"changes"`
+}
+
+// errInternalAddress is returned when a webhook would be sent to an address that isn't reachable from the internet
+var errInternalAddress = errors.New("webhooks can't be sent to internal addresses")
+
+// publicIP reports whether the address is reachable from the internet, as opposed to e.g. loopback,
+// private networks or link-local addresses like the cloud metadata service at 169.254.169.254
+func publicIP(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate()
+}
+
+// newWebhookClient returns the client delivering webhooks. It checks the address on every connection,
+// as the host of a webhook may resolve to an internal address after it was registered.
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(_ string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return errInternalAddress
+			}
+			return nil
+		},
+	}
+	// no proxy from the environment, as the dialer could only check the address of the proxy
+	transport := &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: 5 * time.Second}
+	return &http.Client{Timeout: 10 * time.Second, Transport: transport}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b) // never returns an error
+	return hex.EncodeToString(b)
+}
+
+// signPayload returns the signature of the body, sent as X-Signature-256 so receivers can verify it came from us
+func signPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliverWebhook posts the body to the webhook, retrying with exponential backoff on errors and non-2xx responses
+func (a *App) deliverWebhook(hook Webhook, body []byte) error {
+	backoff := a.webhookBackoff
+	var err error
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		if err = postWebhook(a.webhookClient, hook, body); err == nil {
+			return nil
+		}
+		if attempt < webhookAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+	return fmt.Errorf("webhook %s failed after %d attempts: %w", hook.ID, webhookAttempts, err)
+}
+
+func postWebhook(client *http.Client, hook Webhook, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "TUM-Calendar-Proxy/"+Version)
+	req.Header.Set("X-Webhook-ID", hook.ID)
+	req.Header.Set("X-Signature-256", signPayload(hook.Secret, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// notifyWebhooks sends the relevant changes of a feed to all of its webhooks in the background.
+// The returned channel is closed once all deliveries are done.
+func (a *App) notifyWebhooks(id string, changes []Change) <-chan struct{} {
+	done := make(chan struct{})
+	var hooks []Webhook
+	if _, err := a.store.Load("webhooks", id, &hooks); err != nil {
+		sentry.CaptureException(err)
+	}
+
+	var relevant []Change
+	for _, c := range changes {
+		if slices.Contains(webhookChanges, c.Type) {
+			relevant = append(relevant, c)
+		}
+	}
+	if len(hooks) == 0 || len(relevant) == 0 {
+		close(done)
+		return done
+	}
+
+	body, err := json.Marshal(webhookPayload{Feed: id, Changes: relevant})
+	if err != nil {
+		sentry.CaptureException(err)
+		close(done)
+		return done
+	}
+	go func() {
+		defer close(done)
+		for _, hook := range hooks {
+			if err := a.deliverWebhook(hook, body); err != nil {
+				log.Print(err)
+			}
+		}
+	}()
+	return done
+}
+
+// validWebhookURL only accepts absolute http(s) URLs of hosts that don't resolve to internal addresses.
+// Hosts that can't be resolved yet are accepted, the webhook client checks the address again on delivery.
+func validWebhookURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Hostname() == "" {
+		return false
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil {
+		return publicIP(ip)
+	}
+	ips, _ := net.LookupIP(u.Hostname())
+	return !slices.ContainsFunc(ips, func(ip net.IP) bool { return !publicIP(ip) })
+}
+
+// webhooksFor loads the webhooks of the requested feed and holds them until unlock is called, so they can be updated
+func (a *App) webhooksFor(ctx *gin.Context) (string, []Webhook, func(), bool) {
+	if a.store == nil {
+		ctx.AbortWithStatusJSON(http.StatusNotImplemented, gin.H{"error": "change tracking is disabled"})
+		return "", nil, nil, false
+	}
+	if getCalendarURL(ctx) == "" {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "pStud or pPers and pToken are required"})
+		return "", nil, nil, false
+	}
+	id := changesID(ctx)
+	unlock := a.store.Lock("webhooks", id)
+	var hooks []Webhook
+	if _, err := a.store.Load("webhooks", id, &hooks); err != nil {
+		unlock()
+		sentry.CaptureException(err)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return "", nil, nil, false
+	}
+	return id, hooks, unlock, true
+}
+
+// withoutSecrets hides the secrets, which are only returned once on registration
+func withoutSecrets(hooks []Webhook) []Webhook {
+	listed := make([]Webhook, 0, len(hooks))
+	for _, hook := range hooks {
+		hook.Secret = ""
+		listed = append(listed, hook)
+	}
+	return listed
+}
+
+// handleGetWebhooks lists the webhooks registered for a feed.
+func (a *App) handleGetWebhooks(ctx *gin.Context) {
+	_, hooks, unlock, ok := a.webhooksFor(ctx)
+	if !ok {
+		return
+	}
+	unlock()
+	ctx.JSON(http.StatusOK, withoutSecrets(hooks))
+}
+
+// handleAddWebhook registers a webhook for the changes of a feed. The body is {"url": "...", "secret": "..."},
+// without a secret one is generated. The response contains the secret for verifying the signatures.
+func (a *App) handleAddWebhook(ctx *gin.Context) {
+	id, hooks, unlock, ok := a.webhooksFor(ctx)
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	var hook Webhook
+	if err := ctx.ShouldBindJSON(&hook); err != nil || !validWebhookURL(hook.URL) {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "a http(s) url is required"})
+		return
+	}
+	if len(hooks) >= maxWebhooks {
+		ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("at most %d webhooks can be registered", maxWebhooks)})
+		return
+	}
+	hook.ID = randomHex(8)
+	hook.Created = time.Now()
+	if hook.Secret == "" {
+		hook.Secret = randomHex(32)
+	}
+
+	if err := a.store.Save("webhooks", id, append(hooks, hook)); err != nil {
+		sentry.CaptureException(err)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	ctx.JSON(http.StatusCreated, hook)
+}
+
+// handleDeleteWebhook removes a webhook of a feed.
+func (a *App) handleDeleteWebhook(ctx *gin.Context) {
+	id, hooks, unlock, ok := a.webhooksFor(ctx)
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	remaining := slices.DeleteFunc(hooks, func(hook Webhook) bool { return hook.ID == ctx.Param("id") })
+	if len(remaining) == len(hooks) {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	if err := a.store.Save("webhooks", id, remaining); err != nil {
+		sentry.CaptureException(err)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
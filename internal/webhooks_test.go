@@ -0,0 +1,194 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// webhookReceiver is a local stand-in for a bot receiving webhooks, answering the first few requests with an error
+type webhookReceiver struct {
+	mu       sync.Mutex
+	failures int
+	attempts int
+	bodies   [][]byte
+	headers  []http.Header
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempts++
+	if r.attempts <= r.failures {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	body, _ := io.ReadAll(req.Body)
+	r.bodies = append(r.bodies, body)
+	r.headers = append(r.headers, req.Header)
+}
+
+func newWebhookTestApp(t *testing.T) *App {
+	_, app := getTestData(t, "location.ics")
+	store, err := newStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.store = store
+	app.webhookBackoff = time.Millisecond
+	// the receivers listen on localhost, which the client of the app refuses to connect to
+	app.webhookClient = &http.Client{Timeout: time.Second}
+	return app
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	receiver := &webhookReceiver{failures: 2}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	app := newWebhookTestApp(t)
+	hook := Webhook{ID: "hook", URL: server.URL, Secret: "secret"}
+	if err := app.store.Save("webhooks", "feed", []Webhook{hook}); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	changes := []Change{
+		{Type: changeCancelled, DetectedAt: now, UID: "1", Title: "ERA", Start: now, End: now.Add(time.Hour)},
+		{Type: changeRemoved, DetectedAt: now, UID: "2", Title: "GAD", Start: now, End: now.Add(time.Hour)},
+	}
+	<-app.notifyWebhooks("feed", changes)
+
+	if receiver.attempts != 3 || len(receiver.bodies) != 1 {
+		t.Fatalf("Expected delivery on the third attempt but got %d attempts and %d deliveries", receiver.attempts, len(receiver.bodies))
+	}
+	body := receiver.bodies[0]
+	if signature := receiver.headers[0].Get("X-Signature-256"); signature != signPayload("secret", body) {
+		t.Errorf("Signature %s doesn't match the body", signature)
+	}
+	var payload webhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Feed != "feed" || len(payload.Changes) != 1 || payload.Changes[0].Type != changeCancelled {
+		t.Errorf("Only the cancellation should be delivered but got %v", payload)
+	}
+}
+
+func TestWebhookGivesUp(t *testing.T) {
+	receiver := &webhookReceiver{failures: webhookAttempts}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	app := newWebhookTestApp(t)
+	if err := app.deliverWebhook(Webhook{ID: "hook", URL: server.URL}, []byte("{}")); err == nil {
+		t.Error("Delivery should fail once all attempts failed")
+	}
+	if receiver.attempts != webhookAttempts {
+		t.Errorf("Expected %d attempts but got %d", webhookAttempts, receiver.attempts)
+	}
+}
+
+func TestWebhookInternalAddresses(t *testing.T) {
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	err := postWebhook(newWebhookClient(), Webhook{ID: "hook", URL: server.URL}, []byte("{}"))
+	if !errors.Is(err, errInternalAddress) || receiver.attempts != 0 {
+		t.Errorf("Delivery to localhost should be refused but got %v after %d attempts", err, receiver.attempts)
+	}
+
+	for _, raw := range []string{
+		"http://127.0.0.1/hook",
+		"http://localhost:8080/hook",
+		"http://[::1]/hook",
+		"http://10.0.0.1/hook",
+		"http://192.168.1.1/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://0.0.0.0/hook",
+	} {
+		if validWebhookURL(raw) {
+			t.Errorf("%s should be rejected", raw)
+		}
+	}
+	if !validWebhookURL("https://1.1.1.1/hook") {
+		t.Error("Public addresses should be accepted")
+	}
+}
+
+func TestWebhookRegistration(t *testing.T) {
+	app := newWebhookTestApp(t)
+	gin.SetMode(gin.TestMode)
+	app.engine = gin.New()
+	app.configRoutes()
+	const feed = "/api/webhooks?pStud=ABCDEF&pToken=SECRET"
+
+	request := func(method string, path string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		app.engine.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	if w := request(http.MethodPost, feed, `{"url": "ftp://example.com"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Non-http webhooks should be rejected but got %d", w.Code)
+	}
+	w := request(http.MethodPost, feed, `{"url": "https://example.com/hook"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Registration failed with %d", w.Code)
+	}
+	var hook Webhook
+	if err := json.Unmarshal(w.Body.Bytes(), &hook); err != nil {
+		t.Fatal(err)
+	}
+	if hook.ID == "" || hook.Secret == "" {
+		t.Errorf("Registration should return an id and a generated secret but got %v", hook)
+	}
+
+	w = request(http.MethodGet, feed, "")
+	if strings.Contains(w.Body.String(), hook.Secret) || !strings.Contains(w.Body.String(), hook.ID) {
+		t.Errorf("Listing should contain the webhook without its secret but is %s", w.Body.String())
+	}
+
+	path := strings.Replace(feed, "?", "/"+hook.ID+"?", 1)
+	if w := request(http.MethodDelete, path, ""); w.Code != http.StatusNoContent {
+		t.Errorf("Deletion failed with %d", w.Code)
+	}
+	if w := request(http.MethodDelete, path, ""); w.Code != http.StatusNotFound {
+		t.Errorf("Deleting twice should fail but got %d", w.Code)
+	}
+}
+
+func TestConcurrentWebhookRegistration(t *testing.T) {
+	app := newWebhookTestApp(t)
+	gin.SetMode(gin.TestMode)
+	app.engine = gin.New()
+	app.configRoutes()
+
+	var wg sync.WaitGroup
+	for range 10 * maxWebhooks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			app.engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/webhooks?pStud=ABCDEF&pToken=SECRET", strings.NewReader(`{"url": "https://1.1.1.1/hook"}`)))
+		}()
+	}
+	wg.Wait()
+
+	var hooks []Webhook
+	if _, err := app.store.Load("webhooks", feedID(tumOnlineURL("ABCDEF", "", "SECRET"), nil), &hooks); err != nil {
+		t.Fatal(err)
+	}
+	if len(hooks) != maxWebhooks {
+		t.Errorf("Concurrent registrations should store exactly %d webhooks but stored %d", maxWebhooks, len(hooks))
+	}
+}